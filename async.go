@@ -0,0 +1,203 @@
+package easyfiles
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// AsyncWriterStats is a snapshot of an AsyncWriter's queue
+type AsyncWriterStats struct {
+	// Depth is the number of writes waiting in the queue
+	Depth int
+	// Capacity is the maximum number of writes the queue holds before
+	// Write blocks
+	Capacity int
+	// MaxDepth is the deepest the queue has been
+	MaxDepth int
+	// Enqueued and Written count bytes accepted by Write and bytes
+	// passed on to the underlying writer
+	Enqueued int64
+	Written  int64
+}
+
+type asyncOp struct {
+	data  []byte
+	flush chan error
+}
+
+// AsyncWriter accepts writes into a bounded queue and passes them on to
+// an underlying WriteLayer (compression, I/O, ..) from a background
+// goroutine. Write only blocks once the queue is full. The first error
+// returned by the underlying writer is returned from every subsequent
+// Write, Flush and Close. AsyncWriter is safe for concurrent use.
+type AsyncWriter struct {
+	next     WriteLayer
+	file     *File
+	queue    chan asyncOp
+	done     chan struct{}
+	capacity int
+
+	mutex  sync.RWMutex
+	closed bool
+
+	errMutex sync.Mutex
+	err      error
+
+	maxDepth int64
+	enqueued int64
+	written  int64
+}
+
+// NewAsyncWriter starts a background goroutine writing into next. The
+// queue holds up to queueSize writes; a queueSize of 0 uses 64.
+func NewAsyncWriter(next WriteLayer, queueSize int) *AsyncWriter {
+	if queueSize <= 0 {
+		queueSize = 64
+	}
+	a := &AsyncWriter{
+		next:     next,
+		queue:    make(chan asyncOp, queueSize),
+		done:     make(chan struct{}),
+		capacity: queueSize,
+	}
+	go a.run()
+	return a
+}
+
+// AsyncWriter returns an AsyncWriter on top of Writer(bufsize). Closing
+// the File closes the AsyncWriter, draining its queue first.
+func (f *File) AsyncWriter(bufsize int, queueSize int) (*AsyncWriter, error) {
+	w, err := f.Writer(bufsize)
+	if err != nil {
+		return nil, err
+	}
+	// The AsyncWriter closes w once its queue has drained
+	f.untrack(w)
+	a := NewAsyncWriter(w, queueSize)
+	a.file = f
+	if err := f.track(a); err != nil {
+		a.Close()
+		return nil, err
+	}
+	return a, nil
+}
+
+func (a *AsyncWriter) run() {
+	defer close(a.done)
+	for op := range a.queue {
+		err := a.Err()
+		if op.flush != nil {
+			if err == nil {
+				err = a.next.Flush()
+				a.setErr(err)
+			}
+			op.flush <- err
+			continue
+		}
+		if err != nil {
+			// Drop everything after the first error
+			continue
+		}
+		n, err := a.next.Write(op.data)
+		atomic.AddInt64(&a.written, int64(n))
+		a.setErr(err)
+	}
+}
+
+func (a *AsyncWriter) setErr(err error) {
+	if err == nil {
+		return
+	}
+	a.errMutex.Lock()
+	defer a.errMutex.Unlock()
+	if a.err == nil {
+		a.err = err
+	}
+}
+
+// Err returns the first error encountered by the background goroutine
+func (a *AsyncWriter) Err() error {
+	a.errMutex.Lock()
+	defer a.errMutex.Unlock()
+	return a.err
+}
+
+func (a *AsyncWriter) enqueue(op asyncOp) error {
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+	if a.closed {
+		return ErrWriterClosed
+	}
+	a.queue <- op
+	depth := int64(len(a.queue))
+	for {
+		max := atomic.LoadInt64(&a.maxDepth)
+		if depth <= max || atomic.CompareAndSwapInt64(&a.maxDepth, max, depth) {
+			break
+		}
+	}
+	return nil
+}
+
+// Write queues a copy of p. It returns an error if an earlier write failed.
+func (a *AsyncWriter) Write(p []byte) (int, error) {
+	if err := a.Err(); err != nil {
+		return 0, err
+	}
+	data := make([]byte, len(p))
+	copy(data, p)
+	if err := a.enqueue(asyncOp{data: data}); err != nil {
+		return 0, err
+	}
+	atomic.AddInt64(&a.enqueued, int64(len(p)))
+	return len(p), nil
+}
+
+// Flush waits for everything queued so far to be written and flushed
+func (a *AsyncWriter) Flush() error {
+	reply := make(chan error, 1)
+	if err := a.enqueue(asyncOp{flush: reply}); err != nil {
+		return err
+	}
+	return <-reply
+}
+
+// Close drains the queue and closes the underlying writer. Closing a
+// closed AsyncWriter returns the first error encountered, if any.
+func (a *AsyncWriter) Close() error {
+	a.mutex.Lock()
+	if a.closed {
+		a.mutex.Unlock()
+		<-a.done
+		return a.Err()
+	}
+	a.closed = true
+	close(a.queue)
+	a.mutex.Unlock()
+
+	<-a.done
+	if a.file != nil {
+		a.file.untrack(a)
+	}
+	if err := a.Err(); err != nil {
+		a.next.Close()
+		return err
+	}
+	return a.next.Close()
+}
+
+// QueueDepth returns the number of writes waiting in the queue
+func (a *AsyncWriter) QueueDepth() int {
+	return len(a.queue)
+}
+
+// Stats returns a snapshot of the queue's metrics
+func (a *AsyncWriter) Stats() AsyncWriterStats {
+	return AsyncWriterStats{
+		Depth:    len(a.queue),
+		Capacity: a.capacity,
+		MaxDepth: int(atomic.LoadInt64(&a.maxDepth)),
+		Enqueued: atomic.LoadInt64(&a.enqueued),
+		Written:  atomic.LoadInt64(&a.written),
+	}
+}
@@ -0,0 +1,99 @@
+package easyfiles
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type failingLayer struct {
+	after int
+	count int
+}
+
+func (l *failingLayer) Write(p []byte) (int, error) {
+	if l.count >= l.after {
+		return 0, errors.New("disk on fire")
+	}
+	l.count++
+	return len(p), nil
+}
+
+func (l *failingLayer) Flush() error {
+	return nil
+}
+
+func (l *failingLayer) Close() error {
+	return nil
+}
+
+func TestAsyncWriter(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	for _, fileType := range []FileType{GZ_TRUE, GZ_FALSE} {
+		fname := fmt.Sprintf("/tmp/async-writer-%v", fileType)
+		defer os.Remove(fname)
+
+		f, err := Open(fname, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileType)
+		require.Nil(err)
+		w, err := f.AsyncWriter(0, 4)
+		require.Nil(err)
+
+		data := RandomData(1024 * 1024)
+		for idx := 0; idx < len(data); idx += 1000 {
+			end := idx + 1000
+			if end > len(data) {
+				end = len(data)
+			}
+			_, err = w.Write(data[idx:end])
+			require.Nil(err)
+		}
+		require.Nil(w.Flush())
+		stats := w.Stats()
+		require.Equal(0, stats.Depth)
+		require.Equal(4, stats.Capacity)
+		require.True(stats.MaxDepth <= 4)
+		require.Equal(int64(len(data)), stats.Enqueued)
+		require.Equal(int64(len(data)), stats.Written)
+
+		// Closing the file drains and closes the async writer
+		require.Nil(f.Close())
+		_, err = w.Write([]byte("late"))
+		require.Equal(ErrWriterClosed, err)
+
+		f, err = Open(fname, os.O_RDONLY, fileType)
+		require.Nil(err)
+		success, err := CheckFileContentsMatch(f, data, true, 0)
+		require.Nil(err)
+		require.True(success)
+		f.Close()
+	}
+}
+
+func TestAsyncWriterError(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	w := NewAsyncWriter(&failingLayer{after: 2}, 1)
+	wg := sync.WaitGroup{}
+	for idx := 0; idx < 8; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.Write([]byte("data"))
+		}()
+	}
+	wg.Wait()
+
+	require.NotNil(w.Flush())
+	// The error sticks
+	_, err := w.Write([]byte("more"))
+	require.NotNil(err)
+	require.Equal("disk on fire", w.Close().Error())
+	require.NotNil(w.Close())
+}
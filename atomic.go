@@ -0,0 +1,151 @@
+package easyfiles
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type syncer interface {
+	Sync() error
+}
+
+type dirSyncer interface {
+	syncDir(string) error
+}
+
+type aborter interface {
+	Abort() error
+}
+
+// atomicFile writes to a temporary sibling of path and renames it into
+// place on Close
+type atomicFile struct {
+	FileInterface
+	fs     FileSystemInterface
+	tmp    string
+	path   string
+	failed bool
+	done   bool
+}
+
+// CreateAtomic creates a File that is written to a hidden temporary file
+// next to path. Closing the File renames the temporary file to path,
+// replacing whatever was there. If any write failed, or the File is
+// aborted with Abort, the temporary file is removed and path is left
+// untouched. Files with a .gz suffix are gzip files.
+//
+// On LocalFS, the file is fsynced before the rename and the directory
+// after it. The filesystem must implement Renamer.
+func CreateAtomic(fs FileSystemInterface, path string) (*File, error) {
+	if _, ok := fs.(Renamer); !ok {
+		return nil, &os.PathError{Op: "create", Path: path, Err: ErrNotSupported}
+	}
+	gz := GZ_FALSE
+	if strings.HasSuffix(path, ".gz") {
+		gz = GZ_TRUE
+	}
+
+	dir, base := filepath.Split(path)
+	mode := os.O_CREATE | os.O_EXCL | os.O_TRUNC | os.O_WRONLY
+	var err error
+	for i := 0; i < 10000; i++ {
+		tmp := filepath.Join(dir, "."+base+".tmp-"+nextSuffix())
+		var exists bool
+		if exists, err = fs.Exists(tmp); err != nil {
+			return nil, err
+		} else if exists {
+			continue
+		}
+		var f *File
+		if f, err = fs.Open(tmp, mode, gz); err != nil {
+			if os.IsExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		f.Path = path
+		f.File = &atomicFile{f.File, fs, tmp, path, false, false}
+		return f, nil
+	}
+	return nil, err
+}
+
+// WriteFileAtomic is the atomic counterpart of WriteFile. The temporary
+// file is given perm before it is renamed into place, so the filesystem
+// must implement Chmoder as well as Renamer.
+func WriteFileAtomic(fs FileSystemInterface, path string, b []byte, perm os.FileMode) error {
+	f, err := CreateAtomic(fs, path)
+	if err != nil {
+		return err
+	}
+	if err = Chmod(fs, f.File.(*atomicFile).tmp, perm); err != nil {
+		f.Abort()
+		return err
+	}
+	if _, err = f.File.Write(b); err != nil {
+		f.Abort()
+		return err
+	}
+	return f.Close()
+}
+
+func (a *atomicFile) Write(p []byte) (int, error) {
+	n, err := a.FileInterface.Write(p)
+	if err != nil {
+		a.failed = true
+	}
+	return n, err
+}
+
+func (a *atomicFile) Close() error {
+	if a.done {
+		return nil
+	}
+	if a.failed {
+		a.Abort()
+		return errors.New("Not renaming " + a.tmp + " to " + a.path + " after failed write")
+	}
+	a.done = true
+
+	if s, ok := a.FileInterface.(syncer); ok {
+		if err := s.Sync(); err != nil {
+			a.FileInterface.Close()
+			a.fs.Remove(a.tmp)
+			return err
+		}
+	}
+	if err := a.FileInterface.Close(); err != nil {
+		a.fs.Remove(a.tmp)
+		return err
+	}
+	if err := a.fs.(Renamer).Rename(a.tmp, a.path); err != nil {
+		a.fs.Remove(a.tmp)
+		return err
+	}
+	if d, ok := a.fs.(dirSyncer); ok {
+		dir := filepath.Dir(a.path)
+		return d.syncDir(dir)
+	}
+	return nil
+}
+
+// Abort closes the file and removes it without renaming it into place
+func (a *atomicFile) Abort() error {
+	if a.done {
+		return nil
+	}
+	a.done = true
+	a.FileInterface.Close()
+	return a.fs.Remove(a.tmp)
+}
+
+// Abort discards a File created with CreateAtomic. For any other File it
+// is the same as Close.
+func (f *File) Abort() error {
+	if a, ok := f.File.(aborter); ok {
+		return a.Abort()
+	}
+	return f.Close()
+}
@@ -0,0 +1,107 @@
+package easyfiles
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAtomic(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "atomic")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "out.gz")
+	require.Nil(ioutil.WriteFile(fname, []byte("old"), 0664))
+
+	f, err := CreateAtomic(LocalFS, fname)
+	require.Nil(err)
+	require.Equal(GZ_TRUE, f.Gz)
+	w, err := f.Writer(0)
+	require.Nil(err)
+	w.Write([]byte("new"))
+	require.Nil(w.Close())
+
+	// Until the file is closed, the old contents are still in place
+	b, err := ioutil.ReadFile(fname)
+	require.Nil(err)
+	require.Equal("old", string(b))
+	infos, err := ioutil.ReadDir(dir)
+	require.Nil(err)
+	require.Equal(2, len(infos))
+
+	require.Nil(f.Close())
+	require.Nil(f.Close())
+
+	infos, err = ioutil.ReadDir(dir)
+	require.Nil(err)
+	require.Equal(1, len(infos))
+	f, err = Open(fname, os.O_RDONLY, GZ_UNKNOWN)
+	require.Nil(err)
+	defer f.Close()
+	success, err := CheckFileContentsMatch(f, []byte("new"), true, 0)
+	require.Nil(err)
+	require.True(success)
+}
+
+func TestCreateAtomicAbort(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "atomic")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "out.txt")
+	f, err := CreateAtomic(LocalFS, fname)
+	require.Nil(err)
+	_, err = f.File.Write([]byte("partial"))
+	require.Nil(err)
+	require.Nil(f.Abort())
+	require.Nil(f.Close())
+
+	infos, err := ioutil.ReadDir(dir)
+	require.Nil(err)
+	require.Equal(0, len(infos))
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "atomic")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "out.txt")
+	require.Nil(WriteFileAtomic(LocalFS, fname, []byte("Hello World\n"), 0600))
+	info, err := os.Stat(fname)
+	require.Nil(err)
+	require.Equal(os.FileMode(0600), info.Mode().Perm())
+	b, err := ioutil.ReadFile(fname)
+	require.Nil(err)
+	require.Equal("Hello World\n", string(b))
+
+	// Sidecars follow the rename on a ChecksumFileSystem
+	fs := NewChecksumFileSystem(LocalFS, 0)
+	require.Nil(WriteFileAtomic(fs, fname, []byte("checked"), 0664))
+	b, err = fs.ReadFile(fname)
+	require.Nil(err)
+	require.Equal("checked", string(b))
+	infos, err := fs.ReadDir(dir)
+	require.Nil(err)
+	require.Equal(1, len(infos))
+
+	// Permissions apply on every filesystem, not just LocalFS
+	mem := NewMemFS()
+	require.Nil(WriteFileAtomic(mem, "/out.txt", []byte("mem"), 0600))
+	info, err = mem.Stat("/out.txt")
+	require.Nil(err)
+	require.Equal(os.FileMode(0600), info.Mode().Perm())
+}
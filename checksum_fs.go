@@ -0,0 +1,411 @@
+package easyfiles
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_CRC_CHUNK_SIZE = 512
+)
+
+var crcMagic = []byte("crc\x00")
+
+// ChecksumError is returned when a chunk read through a
+// ChecksumFileSystem does not match its recorded CRC32C
+type ChecksumError struct {
+	Path     string
+	Offset   int64
+	Expected uint32
+	Actual   uint32
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("Checksum error: %v at offset %d: expected %08x, got %08x", e.Path, e.Offset, e.Expected, e.Actual)
+}
+
+// ChecksumFileSystem wraps a FileSystemInterface and maintains a hidden
+// .<name>.crc sidecar next to every file written through it, holding a
+// CRC32C per chunk of the file's stored bytes. Files are verified chunk
+// by chunk as they are read. Files without a sidecar are read without
+// verification.
+//
+// Files opened for both reading and writing are not verified on read.
+// Writes that don't simply stream from the start of the file (appends,
+// seeks) cause the sidecar to be recomputed from the file on Close.
+type ChecksumFileSystem struct {
+	FileSystemInterface
+	ChunkSize int
+}
+
+// NewChecksumFileSystem wraps fs. A chunkSize of 0 uses DEFAULT_CRC_CHUNK_SIZE.
+func NewChecksumFileSystem(fs FileSystemInterface, chunkSize int) *ChecksumFileSystem {
+	if chunkSize <= 0 {
+		chunkSize = DEFAULT_CRC_CHUNK_SIZE
+	}
+	return &ChecksumFileSystem{fs, chunkSize}
+}
+
+// ChecksumPath returns the path of the CRC sidecar for name
+func ChecksumPath(name string) string {
+	dir, base := filepath.Split(name)
+	return dir + "." + base + ".crc"
+}
+
+func isChecksumPath(name string) bool {
+	base := filepath.Base(name)
+	return strings.HasPrefix(base, ".") && strings.HasSuffix(base, ".crc")
+}
+
+func (c *ChecksumFileSystem) Open(name string, mode int, gz FileType) (*File, error) {
+	streaming := c.streaming(name, mode)
+	f, err := c.FileSystemInterface.Open(name, mode, gz)
+	if err != nil {
+		return nil, err
+	}
+	return c.wrap(f, mode, streaming)
+}
+
+// OpenFile honours every option the underlying filesystem honours
+func (c *ChecksumFileSystem) OpenFile(name string, o *OpenOptions) (*File, error) {
+	if err := o.Validate(); err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	mode := o.OSFlags()
+	streaming := c.streaming(name, mode)
+	f, err := openFile(c.FileSystemInterface, name, o)
+	if err != nil {
+		return nil, err
+	}
+	return c.wrap(f, mode, streaming)
+}
+
+// streaming finds out whether writes to name will be streaming from the
+// start of an empty file
+func (c *ChecksumFileSystem) streaming(name string, mode int) bool {
+	if mode&os.O_TRUNC == 0 {
+		if info, err := c.FileSystemInterface.Stat(name); err == nil && info != nil && info.Size() > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *ChecksumFileSystem) wrap(f *File, mode int, streaming bool) (*File, error) {
+	cf := &checksumFile{
+		fs:        c,
+		name:      f.Path,
+		inner:     f.File,
+		chunkSize: c.ChunkSize,
+	}
+	if mode&(os.O_WRONLY|os.O_RDWR) != 0 {
+		cf.writable = true
+		cf.dirty = !streaming
+		cf.chunk = make([]byte, 0, c.ChunkSize)
+	} else if err := cf.loadChecksums(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	f.File = cf
+	return f, nil
+}
+
+func (c *ChecksumFileSystem) ReadFile(name string) ([]byte, error) {
+	f, err := c.Open(name, os.O_RDONLY, GZ_FALSE)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ioutil.ReadAll(f.File)
+}
+
+func (c *ChecksumFileSystem) WriteFile(name string, b []byte, perm os.FileMode) error {
+	f, err := c.OpenFile(name, NewOpenOptions(WithWrite(), WithCreate(), WithTruncate(), WithPerm(perm), WithCodec(GZ_FALSE)))
+	if err != nil {
+		return err
+	}
+	if _, err = f.File.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (c *ChecksumFileSystem) Remove(name string) error {
+	if err := c.FileSystemInterface.Remove(name); err != nil {
+		return err
+	}
+	return c.removeChecksum(name)
+}
+
+// RemoveAll removes name and everything below it, along with name's own
+// sidecar if it is a file
+func (c *ChecksumFileSystem) RemoveAll(name string) error {
+	if err := c.FileSystemInterface.RemoveAll(name); err != nil {
+		return err
+	}
+	return c.removeChecksum(name)
+}
+
+// Chmod changes the permission bits of the underlying file
+func (c *ChecksumFileSystem) Chmod(name string, mode os.FileMode) error {
+	return Chmod(c.FileSystemInterface, name, mode)
+}
+
+// Chtimes changes the times of the underlying file
+func (c *ChecksumFileSystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return Chtimes(c.FileSystemInterface, name, atime, mtime)
+}
+
+// Rename renames a file along with its sidecar. The underlying
+// filesystem must support renames.
+func (c *ChecksumFileSystem) Rename(oldpath, newpath string) error {
+	r, ok := c.FileSystemInterface.(Renamer)
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: ErrNotSupported}
+	}
+	if err := r.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	if exists, _ := c.FileSystemInterface.Exists(ChecksumPath(oldpath)); exists {
+		return r.Rename(ChecksumPath(oldpath), ChecksumPath(newpath))
+	}
+	// Don't leave a stale sidecar behind at the destination
+	return c.removeChecksum(newpath)
+}
+
+func (c *ChecksumFileSystem) Glob(pattern string) ([]string, error) {
+	matches, err := c.FileSystemInterface.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	// The underlying filesystem's slice is left as it is
+	ret := make([]string, 0, len(matches))
+	for _, m := range matches {
+		if !isChecksumPath(m) {
+			ret = append(ret, m)
+		}
+	}
+	return ret, nil
+}
+
+func (c *ChecksumFileSystem) ReadDir(dirname string) ([]os.FileInfo, error) {
+	infos, err := c.FileSystemInterface.ReadDir(dirname)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]os.FileInfo, 0, len(infos))
+	for _, info := range infos {
+		if !isChecksumPath(info.Name()) {
+			ret = append(ret, info)
+		}
+	}
+	return ret, nil
+}
+
+func (c *ChecksumFileSystem) removeChecksum(name string) error {
+	if exists, _ := c.FileSystemInterface.Exists(ChecksumPath(name)); exists {
+		return c.FileSystemInterface.Remove(ChecksumPath(name))
+	}
+	return nil
+}
+
+func (c *ChecksumFileSystem) writeChecksums(name string, chunkSize int, crcs []uint32) error {
+	buf := bytes.NewBuffer(make([]byte, 0, 8+4*len(crcs)))
+	buf.Write(crcMagic)
+	binary.Write(buf, binary.BigEndian, uint32(chunkSize))
+	binary.Write(buf, binary.BigEndian, crcs)
+	return c.FileSystemInterface.WriteFile(ChecksumPath(name), buf.Bytes(), 0664)
+}
+
+// computeChecksums recomputes the CRCs for name from its stored bytes
+func (c *ChecksumFileSystem) computeChecksums(name string) ([]uint32, error) {
+	f, err := c.FileSystemInterface.Open(name, os.O_RDONLY, GZ_FALSE)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	crcs := make([]uint32, 0)
+	buf := make([]byte, c.ChunkSize)
+	for {
+		n, err := io.ReadFull(f.File, buf)
+		if n > 0 {
+			crcs = append(crcs, crc32.Checksum(buf[:n], crc32cTable))
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return crcs, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+type checksumFile struct {
+	fs        *ChecksumFileSystem
+	name      string
+	inner     FileInterface
+	chunkSize int
+
+	// Writing
+	writable bool
+	dirty    bool
+	chunk    []byte
+	crcs     []uint32
+
+	// Reading
+	verify   bool
+	size     int64
+	pos      int64
+	buf      []byte
+	bufStart int64
+}
+
+func (cf *checksumFile) loadChecksums() error {
+	data, err := cf.fs.FileSystemInterface.ReadFile(ChecksumPath(cf.name))
+	if err != nil {
+		// No sidecar, nothing to verify against
+		return nil
+	}
+	if len(data) < 8 || !bytes.Equal(data[:4], crcMagic) || (len(data)-8)%4 != 0 {
+		return fmt.Errorf("Malformed checksum file: %v", ChecksumPath(cf.name))
+	}
+	cf.chunkSize = int(binary.BigEndian.Uint32(data[4:8]))
+	if cf.chunkSize <= 0 {
+		return fmt.Errorf("Malformed checksum file: %v", ChecksumPath(cf.name))
+	}
+	cf.crcs = make([]uint32, (len(data)-8)/4)
+	binary.Read(bytes.NewReader(data[8:]), binary.BigEndian, cf.crcs)
+
+	info, err := cf.fs.FileSystemInterface.Stat(cf.name)
+	if err != nil {
+		return err
+	} else if info == nil {
+		return &os.PathError{Op: "stat", Path: cf.name, Err: os.ErrNotExist}
+	}
+	cf.size = info.Size()
+	cf.verify = true
+	cf.bufStart = -1
+	return nil
+}
+
+func (cf *checksumFile) Read(p []byte) (int, error) {
+	if !cf.verify {
+		return cf.inner.Read(p)
+	}
+	if cf.pos >= cf.size {
+		return 0, io.EOF
+	}
+	start := cf.pos - cf.pos%int64(cf.chunkSize)
+	if start != cf.bufStart {
+		if err := cf.readChunk(start); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, cf.buf[cf.pos-start:])
+	cf.pos += int64(n)
+	return n, nil
+}
+
+func (cf *checksumFile) readChunk(start int64) error {
+	if _, err := cf.inner.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	length := int64(cf.chunkSize)
+	if start+length > cf.size {
+		length = cf.size - start
+	}
+	if cap(cf.buf) < cf.chunkSize {
+		cf.buf = make([]byte, cf.chunkSize)
+	}
+	cf.buf = cf.buf[:length]
+	if _, err := io.ReadFull(cf.inner, cf.buf); err != nil {
+		cf.bufStart = -1
+		return err
+	}
+	idx := int(start / int64(cf.chunkSize))
+	actual := crc32.Checksum(cf.buf, crc32cTable)
+	if idx >= len(cf.crcs) {
+		cf.bufStart = -1
+		return &ChecksumError{cf.name, start, 0, actual}
+	}
+	if expected := cf.crcs[idx]; expected != actual {
+		cf.bufStart = -1
+		return &ChecksumError{cf.name, start, expected, actual}
+	}
+	cf.bufStart = start
+	return nil
+}
+
+func (cf *checksumFile) Write(p []byte) (int, error) {
+	n, err := cf.inner.Write(p)
+	if cf.writable && !cf.dirty {
+		data := p[:n]
+		for len(data) > 0 {
+			take := cf.chunkSize - len(cf.chunk)
+			if take > len(data) {
+				take = len(data)
+			}
+			cf.chunk = append(cf.chunk, data[:take]...)
+			data = data[take:]
+			if len(cf.chunk) == cf.chunkSize {
+				cf.crcs = append(cf.crcs, crc32.Checksum(cf.chunk, crc32cTable))
+				cf.chunk = cf.chunk[:0]
+			}
+		}
+	}
+	return n, err
+}
+
+func (cf *checksumFile) Seek(offset int64, whence int) (int64, error) {
+	if !cf.verify {
+		if cf.writable {
+			// We can no longer keep track of what ends up where
+			cf.dirty = true
+		}
+		return cf.inner.Seek(offset, whence)
+	}
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = cf.pos + offset
+	case io.SeekEnd:
+		pos = cf.size + offset
+	default:
+		return 0, fmt.Errorf("Seek: invalid whence: %d", whence)
+	}
+	if pos < 0 {
+		return 0, fmt.Errorf("Seek: negative position: %d", pos)
+	}
+	cf.pos = pos
+	return pos, nil
+}
+
+func (cf *checksumFile) Close() error {
+	if err := cf.inner.Close(); err != nil {
+		return err
+	}
+	if !cf.writable {
+		return nil
+	}
+	crcs := cf.crcs
+	if cf.dirty {
+		var err error
+		if crcs, err = cf.fs.computeChecksums(cf.name); err != nil {
+			return err
+		}
+	} else if len(cf.chunk) > 0 {
+		crcs = append(crcs, crc32.Checksum(cf.chunk, crc32cTable))
+	}
+	return cf.fs.writeChecksums(cf.name, cf.fs.ChunkSize, crcs)
+}
@@ -0,0 +1,171 @@
+package easyfiles
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChecksumFileSystemRoundTrip(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "checksum-fs")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	fs := NewChecksumFileSystem(LocalFS, 64)
+	fname := filepath.Join(dir, "data.gz")
+	data := RandomData(64*1024 + 3)
+
+	f, err := fs.Open(fname, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, GZ_TRUE)
+	require.Nil(err)
+	w, err := f.Writer(0)
+	require.Nil(err)
+	w.Write(data)
+	require.Nil(w.Close())
+	require.Nil(f.Close())
+	require.True(Exists(ChecksumPath(fname)))
+
+	f, err = fs.Open(fname, os.O_RDONLY, GZ_TRUE)
+	require.Nil(err)
+	success, err := CheckFileContentsMatch(f, data, true, 0)
+	require.Nil(err)
+	require.True(success)
+	f.Close()
+
+	// Sidecars are hidden from listings
+	infos, err := fs.ReadDir(dir)
+	require.Nil(err)
+	require.Equal(1, len(infos))
+	require.Equal("data.gz", infos[0].Name())
+	matches, err := fs.Glob(filepath.Join(dir, "*"))
+	require.Nil(err)
+	require.Equal([]string{fname}, matches)
+
+	require.Nil(fs.Remove(fname))
+	require.False(Exists(ChecksumPath(fname)))
+}
+
+func TestChecksumFileSystemCorruption(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "checksum-fs")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	fs := NewChecksumFileSystem(LocalFS, 16)
+	fname := filepath.Join(dir, "data.txt")
+	data := RandomData(100)
+	require.Nil(fs.WriteFile(fname, data, 0664))
+
+	got, err := fs.ReadFile(fname)
+	require.Nil(err)
+	require.Equal(data, got)
+
+	// Flip a byte in the fourth chunk behind the filesystem's back
+	corrupt := append([]byte(nil), data...)
+	corrupt[50] ^= 0xff
+	require.Nil(ioutil.WriteFile(fname, corrupt, 0664))
+
+	f, err := fs.Open(fname, os.O_RDONLY, GZ_FALSE)
+	require.Nil(err)
+	defer f.Close()
+	buf := bytes.NewBuffer(nil)
+	_, err = io.Copy(buf, f.File)
+	require.NotNil(err)
+	checksumErr, ok := err.(*ChecksumError)
+	require.True(ok)
+	require.Equal(int64(48), checksumErr.Offset)
+	require.Equal(corrupt[:48], buf.Bytes())
+
+	// Seeking past the corrupt chunk still works
+	_, err = f.Seek(64, io.SeekStart)
+	require.Nil(err)
+	rest, err := ioutil.ReadAll(f.File)
+	require.Nil(err)
+	require.Equal(data[64:], rest)
+}
+
+func TestChecksumFileSystemAppend(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "checksum-fs")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	fs := NewChecksumFileSystem(LocalFS, 16)
+	fname := filepath.Join(dir, "data.txt")
+	require.Nil(fs.WriteFile(fname, []byte("Hello "), 0664))
+
+	f, err := fs.Open(fname, os.O_APPEND|os.O_WRONLY, GZ_FALSE)
+	require.Nil(err)
+	_, err = f.File.Write([]byte("World, this spans a few chunks\n"))
+	require.Nil(err)
+	require.Nil(f.Close())
+
+	got, err := fs.ReadFile(fname)
+	require.Nil(err)
+	require.Equal("Hello World, this spans a few chunks\n", string(got))
+}
+
+// cachedListingFS hands out the same listing slices every time
+type cachedListingFS struct {
+	*MemFS
+	matches []string
+	infos   []os.FileInfo
+}
+
+func (c *cachedListingFS) Glob(pattern string) ([]string, error) {
+	return c.matches, nil
+}
+
+func (c *cachedListingFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	return c.infos, nil
+}
+
+func TestChecksumFileSystemRemoveAll(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mem := NewMemFS()
+	fs := NewChecksumFileSystem(mem, 0)
+	require.Nil(fs.WriteFile("/data.txt", []byte("old"), 0664))
+	require.Nil(fs.RemoveAll("/data.txt"))
+	exists, err := mem.Exists(ChecksumPath("/data.txt"))
+	require.Nil(err)
+	require.False(exists)
+
+	// A new file by the same name isn't checked against the old sidecar
+	f, err := mem.Open("/data.txt", os.O_CREATE|os.O_WRONLY, GZ_FALSE)
+	require.Nil(err)
+	_, err = f.File.Write([]byte("new"))
+	require.Nil(err)
+	require.Nil(f.Close())
+	b, err := fs.ReadFile("/data.txt")
+	require.Nil(err)
+	require.Equal("new", string(b))
+
+	// Filtering listings leaves the underlying filesystem's slices alone
+	require.Nil(fs.WriteFile("/other.txt", nil, 0664))
+	infos, err := mem.ReadDir("/")
+	require.Nil(err)
+	cached := &cachedListingFS{mem, []string{"/data.txt", "/.data.txt.crc", "/other.txt"}, infos}
+	before := names(infos)
+	fs = NewChecksumFileSystem(cached, 0)
+	matches, err := fs.Glob("/*")
+	require.Nil(err)
+	require.Equal([]string{"/data.txt", "/other.txt"}, matches)
+	require.Equal([]string{"/data.txt", "/.data.txt.crc", "/other.txt"}, cached.matches)
+	infos, err = fs.ReadDir("/")
+	require.Nil(err)
+	require.Equal([]string{"data.txt", "other.txt"}, names(infos))
+	require.Equal(before, names(cached.infos))
+}
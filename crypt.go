@@ -0,0 +1,365 @@
+package easyfiles
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Encrypted files start with a header
+//
+//	magic "EZEC" | version (1) | chunk size (4) | key ID length (2) | key ID | nonce (12)
+//
+// followed by the plaintext split into chunks of chunk size bytes, each
+// sealed with AES-GCM. Only the last chunk may be shorter, and there is
+// always at least one chunk. Every chunk is authenticated together with
+// the header, its index and whether it is the last chunk, so reordering,
+// truncating or extending a file is detected just like modifying it.
+
+const (
+	DEFAULT_CRYPT_CHUNK_SIZE = 64 * 1024
+	cryptVersion             = 1
+	cryptNonceSize           = 12
+)
+
+var cryptMagic = []byte("EZEC")
+
+// KeyProvider hands out encryption keys by ID. Keys must be 16, 24 or 32
+// bytes long to select AES-128, AES-192 or AES-256.
+type KeyProvider interface {
+	Key(id string) ([]byte, error)
+}
+
+// StaticKeys is a KeyProvider backed by a map of key ID to key
+type StaticKeys map[string][]byte
+
+func (s StaticKeys) Key(id string) ([]byte, error) {
+	if key, ok := s[id]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("Unknown key ID: %q", id)
+}
+
+// Encryption describes how new files are to be encrypted
+type Encryption struct {
+	KeyID     string
+	Keys      KeyProvider
+	ChunkSize int
+}
+
+// DecryptionError is returned when a chunk fails authentication. Nothing
+// from a chunk is returned until it has been authenticated.
+type DecryptionError struct {
+	Chunk  int64
+	Offset int64
+}
+
+func (e *DecryptionError) Error() string {
+	return fmt.Sprintf("Failed to authenticate chunk %d at offset %d", e.Chunk, e.Offset)
+}
+
+func newGCM(keys KeyProvider, keyID string) (cipher.AEAD, error) {
+	if keys == nil {
+		return nil, errors.New("No key provider")
+	}
+	key, err := keys.Key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(base []byte, idx int64) []byte {
+	nonce := make([]byte, cryptNonceSize)
+	copy(nonce, base)
+	counter := binary.BigEndian.Uint64(nonce[4:]) ^ uint64(idx)
+	binary.BigEndian.PutUint64(nonce[4:], counter)
+	return nonce
+}
+
+func chunkAAD(header []byte, idx int64, final bool) []byte {
+	aad := make([]byte, len(header)+9)
+	copy(aad, header)
+	binary.BigEndian.PutUint64(aad[len(header):], uint64(idx))
+	if final {
+		aad[len(aad)-1] = 1
+	}
+	return aad
+}
+
+// EncryptWriter encrypts everything written to it into an underlying
+// writer. Close must be called to write out the final chunk; it does not
+// close the underlying writer.
+type EncryptWriter struct {
+	w         io.Writer
+	gcm       cipher.AEAD
+	header    []byte
+	nonce     []byte
+	chunkSize int
+	buf       []byte
+	idx       int64
+	closed    bool
+}
+
+// NewEncryptWriter writes the header for enc to w and returns a writer
+// that encrypts into w
+func NewEncryptWriter(w io.Writer, enc *Encryption) (*EncryptWriter, error) {
+	if len(enc.KeyID) > 0xffff {
+		return nil, errors.New("Key ID too long")
+	}
+	gcm, err := newGCM(enc.Keys, enc.KeyID)
+	if err != nil {
+		return nil, err
+	}
+	chunkSize := enc.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = DEFAULT_CRYPT_CHUNK_SIZE
+	}
+	nonce := make([]byte, cryptNonceSize)
+	if _, err := io.ReadFull(crand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	header := bytes.NewBuffer(nil)
+	header.Write(cryptMagic)
+	header.WriteByte(cryptVersion)
+	binary.Write(header, binary.BigEndian, uint32(chunkSize))
+	binary.Write(header, binary.BigEndian, uint16(len(enc.KeyID)))
+	header.WriteString(enc.KeyID)
+	header.Write(nonce)
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return nil, err
+	}
+
+	return &EncryptWriter{
+		w:         w,
+		gcm:       gcm,
+		header:    header.Bytes(),
+		nonce:     nonce,
+		chunkSize: chunkSize,
+		buf:       make([]byte, 0, chunkSize),
+	}, nil
+}
+
+func (e *EncryptWriter) Write(p []byte) (n int, err error) {
+	if e.closed {
+		return 0, errors.New("Write to closed EncryptWriter")
+	}
+	for len(p) > 0 {
+		// A full chunk is only sealed once we know it isn't the last one
+		if len(e.buf) == e.chunkSize {
+			if err = e.seal(false); err != nil {
+				return
+			}
+		}
+		take := e.chunkSize - len(e.buf)
+		if take > len(p) {
+			take = len(p)
+		}
+		e.buf = append(e.buf, p[:take]...)
+		p = p[take:]
+		n += take
+	}
+	return
+}
+
+func (e *EncryptWriter) seal(final bool) error {
+	sealed := e.gcm.Seal(nil, chunkNonce(e.nonce, e.idx), e.buf, chunkAAD(e.header, e.idx, final))
+	if _, err := e.w.Write(sealed); err != nil {
+		return err
+	}
+	e.idx++
+	e.buf = e.buf[:0]
+	return nil
+}
+
+// Close seals the final chunk
+func (e *EncryptWriter) Close() error {
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.seal(true)
+}
+
+// DecryptReader decrypts a stream written by EncryptWriter. It supports
+// seeking to any plaintext offset; only the chunk containing the offset
+// is read and authenticated.
+type DecryptReader struct {
+	r         io.ReadSeeker
+	gcm       cipher.AEAD
+	header    []byte
+	nonce     []byte
+	KeyID     string
+	chunkSize int64
+	numChunks int64
+	lastSize  int64 // ciphertext size of the last chunk
+	size      int64 // plaintext size
+
+	pos      int64
+	buf      []byte
+	bufChunk int64
+}
+
+// NewDecryptReader reads the header from r and looks up its key in keys
+func NewDecryptReader(r io.ReadSeeker, keys KeyProvider) (*DecryptReader, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	fixed := make([]byte, 11)
+	if _, err := io.ReadFull(r, fixed); err != nil {
+		return nil, fmt.Errorf("Failed to read encryption header: %v", err)
+	}
+	if !bytes.Equal(fixed[:4], cryptMagic) {
+		return nil, errors.New("Not an encrypted file")
+	}
+	if fixed[4] != cryptVersion {
+		return nil, fmt.Errorf("Unsupported encryption version: %d", fixed[4])
+	}
+	chunkSize := int64(binary.BigEndian.Uint32(fixed[5:9]))
+	keyIDLen := int(binary.BigEndian.Uint16(fixed[9:11]))
+	rest := make([]byte, keyIDLen+cryptNonceSize)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, fmt.Errorf("Failed to read encryption header: %v", err)
+	}
+	if chunkSize <= 0 {
+		return nil, errors.New("Malformed encryption header")
+	}
+	keyID := string(rest[:keyIDLen])
+
+	gcm, err := newGCM(keys, keyID)
+	if err != nil {
+		return nil, err
+	}
+
+	headerLen := int64(len(fixed) + len(rest))
+	end, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return nil, err
+	}
+	overhead := int64(gcm.Overhead())
+	sealedChunk := chunkSize + overhead
+	body := end - headerLen
+	if body < overhead {
+		return nil, &DecryptionError{0, headerLen}
+	}
+	numChunks := (body + sealedChunk - 1) / sealedChunk
+	lastSize := body - (numChunks-1)*sealedChunk
+	if lastSize < overhead {
+		return nil, &DecryptionError{numChunks - 1, headerLen + (numChunks-1)*sealedChunk}
+	}
+
+	return &DecryptReader{
+		r:         r,
+		gcm:       gcm,
+		header:    append(fixed, rest...),
+		nonce:     rest[keyIDLen:],
+		KeyID:     keyID,
+		chunkSize: chunkSize,
+		numChunks: numChunks,
+		lastSize:  lastSize,
+		size:      (numChunks-1)*chunkSize + lastSize - overhead,
+		bufChunk:  -1,
+	}, nil
+}
+
+// Size returns the size of the plaintext
+func (d *DecryptReader) Size() int64 {
+	return d.size
+}
+
+func (d *DecryptReader) Read(p []byte) (int, error) {
+	if d.pos >= d.size {
+		return 0, io.EOF
+	}
+	idx := d.pos / d.chunkSize
+	if idx != d.bufChunk {
+		if err := d.open(idx); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf[d.pos-idx*d.chunkSize:])
+	d.pos += int64(n)
+	return n, nil
+}
+
+func (d *DecryptReader) open(idx int64) error {
+	sealedChunk := d.chunkSize + int64(d.gcm.Overhead())
+	offset := int64(len(d.header)) + idx*sealedChunk
+	length := sealedChunk
+	final := idx == d.numChunks-1
+	if final {
+		length = d.lastSize
+	}
+	if _, err := d.r.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	sealed := make([]byte, length)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		return err
+	}
+	plain, err := d.gcm.Open(sealed[:0], chunkNonce(d.nonce, idx), sealed, chunkAAD(d.header, idx, final))
+	if err != nil {
+		d.bufChunk = -1
+		return &DecryptionError{idx, offset}
+	}
+	d.buf = plain
+	d.bufChunk = idx
+	return nil
+}
+
+func (d *DecryptReader) Seek(offset int64, whence int) (int64, error) {
+	var pos int64
+	switch whence {
+	case io.SeekStart:
+		pos = offset
+	case io.SeekCurrent:
+		pos = d.pos + offset
+	case io.SeekEnd:
+		pos = d.size + offset
+	default:
+		return 0, fmt.Errorf("Seek: invalid whence: %d", whence)
+	}
+	if pos < 0 {
+		return 0, fmt.Errorf("Seek: negative position: %d", pos)
+	}
+	d.pos = pos
+	return pos, nil
+}
+
+// EncryptedWriter is the encrypting counterpart of Writer. Data is
+// compressed first (if the file is a gzip file) and then encrypted.
+func (f *File) EncryptedWriter(bufsize int, enc *Encryption) (*Writer, error) {
+	layers := append(f.compressionLayers(), EncryptLayer(enc))
+	return f.LayeredWriter(bufsize, layers...)
+}
+
+// DecryptedRawReader is the decrypting counterpart of RawReader
+func (f *File) DecryptedRawReader(keys KeyProvider) (io.Reader, error) {
+	decReader, err := NewDecryptReader(f.File, keys)
+	if err != nil {
+		return nil, err
+	}
+	switch f.Gz {
+	case GZ_TRUE:
+		gzReader, err := gzip.NewReader(decReader)
+		if err != nil {
+			return nil, err
+		}
+		return gzReader, nil
+	case GZ_FALSE:
+		return bufio.NewReader(decReader), nil
+	}
+	panic("Should not have occured..mode should have been fixed on open")
+}
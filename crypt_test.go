@@ -0,0 +1,128 @@
+package easyfiles
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var testKeys = StaticKeys{
+	"test-key": bytes.Repeat([]byte{0x42}, 32),
+}
+
+func testEncryptedRoundTrip(t *testing.T, fileType FileType, size int) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "crypt")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, fmt.Sprintf("crypt-%v-%d", fileType, size))
+	data := RandomData(size)
+	enc := &Encryption{KeyID: "test-key", Keys: testKeys, ChunkSize: 1024}
+
+	f, err := Open(fname, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileType)
+	require.Nil(err)
+	w, err := f.EncryptedWriter(0, enc)
+	require.Nil(err)
+	_, err = w.Write(data)
+	require.Nil(err)
+	require.Nil(w.Close())
+	require.Nil(f.Close())
+
+	stored, err := ioutil.ReadFile(fname)
+	require.Nil(err)
+	if size > 16 {
+		require.False(bytes.Contains(stored, data[:16]))
+	}
+
+	f, err = Open(fname, os.O_RDONLY, fileType)
+	require.Nil(err)
+	defer f.Close()
+	reader, err := f.DecryptedRawReader(testKeys)
+	require.Nil(err)
+	got, err := ioutil.ReadAll(reader)
+	require.Nil(err)
+	require.Equal(data, got)
+}
+
+func TestEncryptedRoundTrip(t *testing.T) {
+	t.Parallel()
+	for _, size := range []int{0, 1, 1024, 1025, 64*1024 + 7} {
+		testEncryptedRoundTrip(t, GZ_FALSE, size)
+		testEncryptedRoundTrip(t, GZ_TRUE, size)
+	}
+}
+
+func TestDecryptSeek(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	data := RandomData(10*1000 + 5)
+	buf := bytes.NewBuffer(nil)
+	w, err := NewEncryptWriter(buf, &Encryption{KeyID: "test-key", Keys: testKeys, ChunkSize: 1000})
+	require.Nil(err)
+	w.Write(data)
+	require.Nil(w.Close())
+
+	r, err := NewDecryptReader(bytes.NewReader(buf.Bytes()), testKeys)
+	require.Nil(err)
+	require.Equal("test-key", r.KeyID)
+	require.Equal(int64(len(data)), r.Size())
+
+	_, err = r.Seek(4321, io.SeekStart)
+	require.Nil(err)
+	got := make([]byte, 1500)
+	_, err = io.ReadFull(r, got)
+	require.Nil(err)
+	require.Equal(data[4321:4321+1500], got)
+
+	_, err = r.Seek(-5, io.SeekEnd)
+	require.Nil(err)
+	tail, err := ioutil.ReadAll(r)
+	require.Nil(err)
+	require.Equal(data[len(data)-5:], tail)
+}
+
+func TestDecryptTampering(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	data := RandomData(3000)
+	buf := bytes.NewBuffer(nil)
+	w, err := NewEncryptWriter(buf, &Encryption{KeyID: "test-key", Keys: testKeys, ChunkSize: 1000})
+	require.Nil(err)
+	w.Write(data)
+	require.Nil(w.Close())
+	sealed := buf.Bytes()
+
+	// Flip a bit in the second chunk
+	tampered := append([]byte(nil), sealed...)
+	tampered[len(tampered)-1500] ^= 1
+	r, err := NewDecryptReader(bytes.NewReader(tampered), testKeys)
+	require.Nil(err)
+	got := bytes.NewBuffer(nil)
+	_, err = io.Copy(got, r)
+	require.NotNil(err)
+	decErr, ok := err.(*DecryptionError)
+	require.True(ok)
+	require.Equal(int64(1), decErr.Chunk)
+	require.Equal(data[:1000], got.Bytes())
+
+	// Dropping the last chunk is detected too
+	truncated := sealed[:len(sealed)-1016]
+	r, err = NewDecryptReader(bytes.NewReader(truncated), testKeys)
+	require.Nil(err)
+	_, err = ioutil.ReadAll(r)
+	require.NotNil(err)
+
+	// Unknown keys are refused up front
+	_, err = NewDecryptReader(bytes.NewReader(sealed), StaticKeys{})
+	require.NotNil(err)
+}
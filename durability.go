@@ -0,0 +1,219 @@
+package easyfiles
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+type SyncMode int
+
+const (
+	// Leave it to the OS
+	SYNC_NONE SyncMode = iota
+	// Sync once when the file is closed
+	SYNC_ON_CLOSE
+	// Sync whenever Durability.Bytes bytes have been written, and on close
+	SYNC_EVERY_BYTES
+	// Sync every Durability.Interval if anything was written, and on close
+	SYNC_INTERVAL
+)
+
+var ErrSyncNotSupported = errors.New("File does not support syncing")
+
+// Durability trades speed for crash safety.
+//
+// On LocalFS, syncing is fsync (or fdatasync when DataOnly is set, where
+// the platform has it). easyhdfs maps every sync onto hflush, which makes
+// data visible to readers and hands it to all datanodes but, as the HDFS
+// client has no hsync, does not wait for it to hit their disks.
+type Durability struct {
+	Mode     SyncMode
+	Bytes    int64
+	Interval time.Duration
+	// DataOnly skips syncing metadata that isn't needed to read the data back
+	DataOnly bool
+	// SyncDir also syncs the parent directory on close so that a newly
+	// created file survives a crash. Only LocalFS supports this.
+	SyncDir bool
+}
+
+// DataSyncer is implemented by files that can sync their data without
+// their metadata
+type DataSyncer interface {
+	Datasync() error
+}
+
+type durableFile struct {
+	FileInterface
+	path   string
+	policy Durability
+
+	mutex    sync.Mutex
+	unsynced int64
+	syncs    int
+	stop     chan struct{}
+	done     chan struct{}
+	closed   bool
+}
+
+// SetDurability applies a durability policy to the file. It must be
+// called before any writers are handed out.
+func (f *File) SetDurability(d Durability) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return os.ErrClosed
+	}
+	if len(f.writers) > 0 {
+		return errors.New("Durability must be set before writers are created")
+	}
+	switch d.Mode {
+	case SYNC_NONE:
+		if !d.SyncDir {
+			return nil
+		}
+	case SYNC_ON_CLOSE:
+	case SYNC_EVERY_BYTES:
+		if d.Bytes <= 0 {
+			return errors.New("SYNC_EVERY_BYTES needs a positive byte count")
+		}
+	case SYNC_INTERVAL:
+		if d.Interval <= 0 {
+			return errors.New("SYNC_INTERVAL needs a positive interval")
+		}
+	default:
+		return errors.New("Unknown sync mode")
+	}
+	if d.Mode != SYNC_NONE {
+		if _, ok := f.File.(syncer); !ok {
+			return ErrSyncNotSupported
+		}
+	}
+	if d.SyncDir {
+		if _, ok := f.File.(*os.File); !ok {
+			return ErrSyncNotSupported
+		}
+	}
+
+	df := &durableFile{
+		FileInterface: f.File,
+		path:          f.Path,
+		policy:        d,
+	}
+	if d.Mode == SYNC_INTERVAL {
+		df.stop = make(chan struct{})
+		df.done = make(chan struct{})
+		go df.syncEvery(d.Interval)
+	}
+	f.File = df
+	return nil
+}
+
+func (d *durableFile) Write(p []byte) (int, error) {
+	n, err := d.FileInterface.Write(p)
+	if d.policy.Mode == SYNC_NONE {
+		return n, err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.unsynced += int64(n)
+	if err == nil && d.policy.Mode == SYNC_EVERY_BYTES && d.unsynced >= d.policy.Bytes {
+		err = d.sync()
+	}
+	return n, err
+}
+
+// Sync syncs the file regardless of policy
+func (d *durableFile) Sync() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.sync()
+}
+
+func (d *durableFile) sync() error {
+	var err error
+	if ds, ok := d.FileInterface.(DataSyncer); ok && d.policy.DataOnly {
+		err = ds.Datasync()
+	} else if of, ok := d.FileInterface.(*os.File); ok && d.policy.DataOnly {
+		err = fdatasync(of)
+	} else if s, ok := d.FileInterface.(syncer); ok {
+		err = s.Sync()
+	} else {
+		err = ErrSyncNotSupported
+	}
+	if err == nil {
+		d.unsynced = 0
+		d.syncs++
+	}
+	return err
+}
+
+func (d *durableFile) syncEvery(interval time.Duration) {
+	defer close(d.done)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-d.stop:
+			return
+		case <-ticker.C:
+			d.mutex.Lock()
+			if d.unsynced > 0 {
+				d.sync()
+			}
+			d.mutex.Unlock()
+		}
+	}
+}
+
+func (d *durableFile) Close() error {
+	d.mutex.Lock()
+	if d.closed {
+		d.mutex.Unlock()
+		return nil
+	}
+	d.closed = true
+	d.mutex.Unlock()
+
+	if d.stop != nil {
+		close(d.stop)
+		<-d.done
+	}
+	var err error
+	if d.policy.Mode != SYNC_NONE {
+		err = d.Sync()
+	}
+	if e := d.FileInterface.Close(); err == nil {
+		err = e
+	}
+	if err == nil && d.policy.SyncDir {
+		err = LocalFS.syncDir(filepath.Dir(d.path))
+	}
+	return err
+}
+
+// RenameSync renames a file and, on filesystems that support it, syncs
+// the directories involved so the rename survives a crash
+func RenameSync(fs FileSystemInterface, oldpath, newpath string) error {
+	r, ok := fs.(Renamer)
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: ErrNotSupported}
+	}
+	if err := r.Rename(oldpath, newpath); err != nil {
+		return err
+	}
+	d, ok := fs.(dirSyncer)
+	if !ok {
+		return nil
+	}
+	if err := d.syncDir(filepath.Dir(newpath)); err != nil {
+		return err
+	}
+	if filepath.Dir(oldpath) != filepath.Dir(newpath) {
+		return d.syncDir(filepath.Dir(oldpath))
+	}
+	return nil
+}
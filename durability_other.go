@@ -0,0 +1,11 @@
+//go:build !linux
+// +build !linux
+
+package easyfiles
+
+import "os"
+
+// fdatasync falls back to a full fsync where fdatasync isn't available
+func fdatasync(f *os.File) error {
+	return f.Sync()
+}
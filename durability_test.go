@@ -0,0 +1,95 @@
+package easyfiles
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurabilityEveryBytes(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "durability")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	f, err := Open(filepath.Join(dir, "out.txt"), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, GZ_FALSE)
+	require.Nil(err)
+	require.Nil(f.SetDurability(Durability{Mode: SYNC_EVERY_BYTES, Bytes: 100, DataOnly: true, SyncDir: true}))
+	df := f.File.(*durableFile)
+
+	w, err := f.Writer(10)
+	require.Nil(err)
+	// Writers must come after the policy
+	require.NotNil(f.SetDurability(Durability{Mode: SYNC_ON_CLOSE}))
+
+	for i := 0; i < 25; i++ {
+		_, err = w.Write(RandomData(10))
+		require.Nil(err)
+	}
+	require.Equal(2, df.syncs)
+	require.Nil(f.Close())
+	require.Equal(3, df.syncs)
+}
+
+func TestDurabilityInterval(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "durability")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	f, err := Open(filepath.Join(dir, "out.gz"), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, GZ_TRUE)
+	require.Nil(err)
+	require.Nil(f.SetDurability(Durability{Mode: SYNC_INTERVAL, Interval: time.Millisecond}))
+	df := f.File.(*durableFile)
+
+	w, err := f.Writer(0)
+	require.Nil(err)
+	w.Write([]byte("Hello World\n"))
+	require.Nil(w.Flush())
+	for i := 0; i < 1000; i++ {
+		df.mutex.Lock()
+		syncs := df.syncs
+		df.mutex.Unlock()
+		if syncs > 0 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	df.mutex.Lock()
+	require.True(df.syncs > 0)
+	df.mutex.Unlock()
+	require.Nil(f.Close())
+
+	f, err = Open(filepath.Join(dir, "out.gz"), os.O_RDONLY, GZ_TRUE)
+	require.Nil(err)
+	defer f.Close()
+	success, err := CheckFileContentsMatch(f, []byte("Hello World\n"), true, 0)
+	require.Nil(err)
+	require.True(success)
+}
+
+func TestDurabilityUnsupported(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "durability")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	fs := NewChecksumFileSystem(LocalFS, 0)
+	f, err := fs.Open(filepath.Join(dir, "out.txt"), os.O_CREATE|os.O_WRONLY, GZ_FALSE)
+	require.Nil(err)
+	require.Equal(ErrSyncNotSupported, f.SetDurability(Durability{Mode: SYNC_ON_CLOSE}))
+	require.NotNil(f.SetDurability(Durability{Mode: SYNC_EVERY_BYTES}))
+	require.Nil(f.Close())
+
+	require.Nil(RenameSync(LocalFS, filepath.Join(dir, "out.txt"), filepath.Join(dir, "moved.txt")))
+}
@@ -21,3 +21,12 @@ func (f *HdfsFile) Close() error {
 	}
 	return nil
 }
+
+// Sync flushes written data out to the datanodes (hflush). The HDFS
+// client has no hsync, so the data may not have reached their disks yet.
+func (f *HdfsFile) Sync() error {
+	if f.FileWriter != nil {
+		return f.FileWriter.Flush()
+	}
+	return nil
+}
@@ -1,12 +1,14 @@
 package easyhdfs
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"syscall"
 	"time"
 
 	"github.com/gurupras/go-easyfiles"
-	"github.com/gurupras/go-hdfs-doublestar"
 	"github.com/gurupras/hdfs"
 	log "github.com/sirupsen/logrus"
 )
@@ -20,7 +22,29 @@ var (
 	HDFS_CONNECT_RETRY_LIMIT = 10
 )
 
+const (
+	// Port of the namenode for hdfs:// URLs that don't have one
+	HDFS_DEFAULT_PORT = "8020"
+)
+
+func init() {
+	// hdfs://nn/x and hdfs://nn:8020/x share a client
+	easyfiles.RegisterScheme("hdfs", func(authority string) (easyfiles.FileSystemInterface, error) {
+		return newHDFSFileSystem(authority)
+	})
+	easyfiles.SetAuthorityNormalizer("hdfs", easyfiles.DefaultPort(HDFS_DEFAULT_PORT))
+}
+
 func NewHDFSFileSystem(addr string) *hdfsFileSystem {
+	fs, err := newHDFSFileSystem(addr)
+	if err != nil {
+		log.Fatalf("Failed to get HDFS client: %v", err)
+		return nil
+	}
+	return fs
+}
+
+func newHDFSFileSystem(addr string) (*hdfsFileSystem, error) {
 	var client *hdfs.Client
 	var err error
 	for idx := 0; idx < HDFS_CONNECT_RETRY_LIMIT; idx++ {
@@ -32,11 +56,9 @@ func NewHDFSFileSystem(addr string) *hdfsFileSystem {
 		break
 	}
 	if err != nil {
-		log.Fatalf("Failed to get HDFS client: %v", err)
-		return nil
+		return nil, err
 	}
-	fs := &hdfsFileSystem{addr, client}
-	return fs
+	return &hdfsFileSystem{addr, client}, nil
 }
 
 func (h *hdfsFileSystem) getClient() (client *hdfs.Client, err error) {
@@ -105,12 +127,82 @@ func (h *hdfsFileSystem) Open(path string, mode int, gz easyfiles.FileType) (*ea
 		}
 		hdfsFile.FileWriter = w
 	}
-	file := &easyfiles.File{path, hdfsFile, mode, gz}
+	file := &easyfiles.File{Path: path, File: hdfsFile, Mode: mode, Gz: gz}
 	// Now make sure you fix GZ_UNKNOWN if it is GZ_UNKNOWN
 	file.FixMode()
 	return file, nil
 }
 
+// OpenFile opens a file as described by o. HDFS can't overwrite files in
+// place, so opening an existing file for writing requires either
+// truncating or appending to it.
+func (h *hdfsFileSystem) OpenFile(path string, o *easyfiles.OpenOptions) (*easyfiles.File, error) {
+	if err := o.Validate(); err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	client, err := h.getClient()
+	if err != nil {
+		return nil, err
+	}
+	if o.Parents {
+		if err := client.MkdirAll(filepath.Dir(path), o.DirPerm); err != nil {
+			return nil, err
+		}
+	}
+
+	writable := o.Flags&easyfiles.OPEN_WRITE != 0
+	create := false
+	stat, err := client.Stat(path)
+	if err == nil {
+		switch {
+		case stat.IsDir() && writable:
+			return nil, &os.PathError{Op: "open", Path: path, Err: syscall.EISDIR}
+		case o.Flags&easyfiles.OPEN_EXCLUSIVE != 0:
+			return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrExist}
+		case o.Flags&easyfiles.OPEN_TRUNCATE != 0:
+			if err := client.Remove(path); err != nil {
+				return nil, err
+			}
+			create = true
+		case writable && o.Flags&easyfiles.OPEN_APPEND == 0:
+			return nil, &os.PathError{Op: "open", Path: path, Err: errors.New("HDFS files can only be truncated or appended to")}
+		}
+	} else if os.IsNotExist(err) {
+		if o.Flags&easyfiles.OPEN_CREATE == 0 {
+			return nil, err
+		}
+		create = true
+	} else {
+		return nil, err
+	}
+
+	if create {
+		log.Debugf("Creating empty file: %v", path)
+		if err := client.CreateEmptyFile(path); err != nil {
+			return nil, err
+		}
+		if err := client.Chmod(path, o.Perm); err != nil {
+			return nil, err
+		}
+	}
+
+	hdfsFile := &HdfsFile{path, nil, nil, client}
+	if hdfsFile.FileReader, err = client.Open(path); err != nil {
+		return nil, err
+	}
+	if writable {
+		if hdfsFile.FileWriter, err = client.Append(path); err != nil {
+			hdfsFile.Close()
+			return nil, err
+		}
+	}
+	file := &easyfiles.File{Path: path, File: hdfsFile, Mode: o.OSFlags(), Gz: o.Codec, Options: o}
+	if file.Gz == easyfiles.GZ_UNKNOWN {
+		file.FixMode()
+	}
+	return file, nil
+}
+
 func (h *hdfsFileSystem) Stat(name string) (os.FileInfo, error) {
 	client, err := h.getClient()
 	if err != nil {
@@ -162,8 +254,59 @@ func (h *hdfsFileSystem) Remove(name string) error {
 	return client.Remove(name)
 }
 
+// RemoveAll removes name and everything below it. Like os.RemoveAll, it
+// returns nil if name doesn't exist.
 func (h *hdfsFileSystem) RemoveAll(name string) error {
-	return h.Remove(name)
+	if err := h.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (h *hdfsFileSystem) Rename(oldpath, newpath string) error {
+	client, err := h.getClient()
+	if err != nil {
+		return err
+	}
+	return client.Rename(oldpath, newpath)
+}
+
+func (h *hdfsFileSystem) Chmod(name string, mode os.FileMode) error {
+	client, err := h.getClient()
+	if err != nil {
+		return err
+	}
+	return client.Chmod(name, mode)
+}
+
+func (h *hdfsFileSystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	client, err := h.getClient()
+	if err != nil {
+		return err
+	}
+	return client.Chtimes(name, atime, mtime)
+}
+
+// Truncate shrinks a file; HDFS can't grow files this way. If the
+// truncation cuts into a block, HDFS finishes it in the background and
+// the file can't be appended to until it has.
+func (h *hdfsFileSystem) Truncate(name string, size int64) error {
+	client, err := h.getClient()
+	if err != nil {
+		return err
+	}
+	info, err := h.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info == nil {
+		return &os.PathError{Op: "truncate", Path: name, Err: os.ErrNotExist}
+	}
+	if size > info.Size() {
+		return &os.PathError{Op: "truncate", Path: name, Err: easyfiles.ErrNotSupported}
+	}
+	_, err = client.Truncate(name, size)
+	return err
 }
 
 func (h *hdfsFileSystem) Makedirs(name string) error {
@@ -194,5 +337,5 @@ func (h *hdfsFileSystem) ReadDir(dirname string) ([]os.FileInfo, error) {
 }
 
 func (h *hdfsFileSystem) Glob(pattern string) ([]string, error) {
-	return hdfs_doublestar.Glob(h, pattern)
+	return easyfiles.Glob(h, pattern)
 }
@@ -168,3 +168,49 @@ func TestManyFilesWriter(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestHDFSGzipAppend(t *testing.T) {
+	require := require.New(t)
+	fs := getHDFS(t)
+
+	file := "/test/test-hdfs-gzip-append.gz"
+	fs.Remove(file)
+	defer fs.Remove(file)
+
+	for _, data := range []string{"Hello ", "World", "\n"} {
+		f, err := easyfiles.OpenGzipAppend(fs, file)
+		require.Nil(err)
+		writer, err := f.Writer(0)
+		require.Nil(err)
+		_, err = writer.Write([]byte(data))
+		require.Nil(err)
+		require.Nil(f.Close())
+	}
+
+	f, err := fs.Open(file, os.O_RDONLY, easyfiles.GZ_TRUE)
+	require.Nil(err)
+	defer f.Close()
+	reader, err := f.RawReader()
+	require.Nil(err)
+	got := bytes.NewBuffer(nil)
+	_, err = io.Copy(got, reader)
+	require.Nil(err)
+	require.Equal("Hello World\n", got.String())
+}
+
+func TestHDFSURL(t *testing.T) {
+	require := require.New(t)
+	require.Contains(easyfiles.Schemes(), "hdfs")
+	getHDFS(t)
+
+	url := fmt.Sprintf("hdfs://%v%v/test-hdfs-url", *hdfsAddr, *hdfsPath)
+	easyfiles.RemoveURL(url)
+	require.Nil(easyfiles.WriteFileURL(url, []byte("hello"), 0664))
+	defer easyfiles.RemoveURL(url)
+	b, err := easyfiles.ReadFileURL(url)
+	require.Nil(err)
+	require.Equal("hello", string(b))
+	matches, err := easyfiles.GlobURL(url + "*")
+	require.Nil(err)
+	require.Equal([]string{url}, matches)
+}
@@ -1,6 +1,9 @@
 package easyfiles
 
-import "os"
+import (
+	"os"
+	"time"
+)
 
 type FileSystemInterface interface {
 	Open(string, int, FileType) (*File, error)
@@ -14,3 +17,47 @@ type FileSystemInterface interface {
 	Glob(string) ([]string, error)
 	ReadDir(string) ([]os.FileInfo, error)
 }
+
+// The interfaces below are optional capabilities. Use the package-level
+// functions of the same name (Rename, Copy, ..) rather than asserting for
+// them directly; those return ErrNotSupported when a filesystem lacks the
+// capability.
+
+// Renamer is implemented by filesystems that can rename files. newpath
+// may be in a different directory on the same filesystem; its parent must
+// already exist. A file at newpath is replaced. A directory at newpath is
+// only replaced by a directory, and only if it is empty.
+type Renamer interface {
+	Rename(oldpath, newpath string) error
+}
+
+// Copier is implemented by filesystems that can copy a file without
+// streaming it through the client. The copy replaces any file at dst and
+// has the same permission bits as src.
+type Copier interface {
+	Copy(src, dst string) error
+}
+
+// Chmoder is implemented by filesystems that can change permission bits
+type Chmoder interface {
+	Chmod(name string, mode os.FileMode) error
+}
+
+// Chtimeser is implemented by filesystems that can change access and
+// modification times. Filesystems that don't track access times ignore
+// atime.
+type Chtimeser interface {
+	Chtimes(name string, atime time.Time, mtime time.Time) error
+}
+
+// Truncater is implemented by filesystems that can change the size of a
+// file in place. Growing a file pads it with zeros where supported.
+type Truncater interface {
+	Truncate(name string, size int64) error
+}
+
+// Symlinker is implemented by filesystems with symbolic links
+type Symlinker interface {
+	Symlink(oldname, newname string) error
+	Readlink(name string) (string, error)
+}
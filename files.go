@@ -8,10 +8,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"sort"
 	"strings"
-
-	"github.com/bmatcuk/doublestar"
+	"sync"
 )
 
 type FileType int
@@ -50,12 +48,20 @@ type File struct {
 	File FileInterface
 	Mode int
 	Gz   FileType
+	// Options the file was opened with through OpenFile, if any
+	Options *OpenOptions
+
+	// Writers handed out by this file that still need to be closed
+	mutex   sync.Mutex
+	writers []WriteLayer
+	closed  bool
 }
 
 type Flusher interface {
 	Flush() error
 }
 
+// Deprecated: writers are built out of WriteLayers
 type IWriter interface {
 	io.Writer
 	Reset(w io.Writer)
@@ -63,10 +69,14 @@ type IWriter interface {
 	//Close() error
 }
 
+// Writer is the top of a stack of WriteLayers handed out by a File.
+// Closing the Writer finalises every layer (gzip trailers and the like)
+// but leaves the file open. Closing the File closes any Writer that is
+// still open, so forgetting Writer.Close no longer loses data.
 type Writer struct {
-	writer *Writer
-	IWriter
-	gz FileType
+	WriteLayer
+	file   *File
+	closed bool
 }
 
 func (f *File) FixMode() {
@@ -100,32 +110,31 @@ func (f *File) FixMode() {
 	}
 }
 
-func (w *Writer) Flush() (err error) {
-	if w.gz == GZ_TRUE {
-		if v, ok := w.IWriter.(*gzip.Writer); ok {
-			return v.Flush()
-		}
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, ErrWriterClosed
 	}
-	// Flush any underlying writer
-	if w.writer != nil {
-		w.writer.Flush()
+	return w.WriteLayer.Write(p)
+}
+
+// Flush pushes everything written so far through every layer into the file
+func (w *Writer) Flush() error {
+	if w.closed {
+		return ErrWriterClosed
 	}
-	v, _ := w.IWriter.(*bufio.Writer)
-	err = v.Flush()
-	return
+	return w.WriteLayer.Flush()
 }
 
-func (w *Writer) Close() (err error) {
-	err = w.IWriter.Flush()
-	if err != nil {
-		return err
+// Close flushes and finalises every layer. Closing a closed Writer is a no-op.
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
 	}
-	if w.gz == GZ_TRUE {
-		if v, ok := w.IWriter.(*gzip.Writer); ok {
-			err = v.Close()
-		}
+	w.closed = true
+	if w.file != nil {
+		w.file.untrack(w)
 	}
-	return
+	return w.WriteLayer.Close()
 }
 
 func (f *File) RawReader() (io.Reader, error) {
@@ -169,36 +178,102 @@ func (f *File) Reader(bufsize int) (*bufio.Scanner, error) {
 	return scanner, err
 }
 
+// Writer returns a buffered writer to the file that compresses data if
+// the file is a gzip file. A bufsize of 0 uses the buffer size the file
+// was opened with, or a default buffer size.
 func (f *File) Writer(bufsize int) (*Writer, error) {
-	gz_open := false
-	var iWriter IWriter
-	var writer *Writer
-	var err error
+	return f.LayeredWriter(bufsize, f.compressionLayers()...)
+}
+
+// LayeredWriter returns a writer that passes data through layers before
+// it reaches the file. Layers are listed from the caller towards the
+// file, so LayeredWriter(0, GzipLayer(-1), EncryptLayer(enc)) compresses
+// and then encrypts. A buffer of bufsize bytes always sits directly on
+// top of the file.
+func (f *File) LayeredWriter(bufsize int, layers ...LayerFunc) (*Writer, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return nil, os.ErrClosed
+	}
+
+	if bufsize == 0 && f.Options != nil {
+		bufsize = f.Options.BufSize
+	}
+	layer, err := BufferLayer(bufsize)(fileLayer{f.File})
+	if err != nil {
+		return nil, err
+	}
+	for idx := len(layers) - 1; idx >= 0; idx-- {
+		if layer, err = layers[idx](layer); err != nil {
+			return nil, err
+		}
+	}
+	w := &Writer{WriteLayer: layer, file: f}
+	f.writers = append(f.writers, w)
+	return w, nil
+}
 
+// compressionLayers returns the layers needed to write in f's format
+func (f *File) compressionLayers() []LayerFunc {
 	switch f.Gz {
 	case GZ_TRUE:
-		gz_open = true
+		level := gzip.DefaultCompression
+		if f.Options != nil {
+			level = f.Options.Level
+		}
+		return []LayerFunc{GzipLayer(level)}
 	case GZ_FALSE:
-		// Nothing to do
-	default:
-		panic("Should not have occured..mode should have been fixed on open")
+		return nil
 	}
+	panic("Should not have occured..mode should have been fixed on open")
+}
 
-	if bufsize != 0 {
-		writer = &Writer{nil, bufio.NewWriterSize(f.File, bufsize), f.Gz}
+// track has File.Close close w
+func (f *File) track(w WriteLayer) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if f.closed {
+		return os.ErrClosed
 	}
+	f.writers = append(f.writers, w)
+	return nil
+}
 
-	if gz_open == true {
-		iWriter = gzip.NewWriter(f.File)
-	} else {
-		iWriter = bufio.NewWriter(f.File)
+func (f *File) untrack(w WriteLayer) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for idx, v := range f.writers {
+		if v == w {
+			f.writers = append(f.writers[:idx], f.writers[idx+1:]...)
+			return
+		}
 	}
-
-	return &Writer{writer, iWriter, f.Gz}, err
 }
 
+// Close closes any writers that are still open, in the order they were
+// handed out, and then closes the file. Closing a closed File is a no-op.
 func (f *File) Close() error {
-	return f.File.Close()
+	f.mutex.Lock()
+	if f.closed {
+		f.mutex.Unlock()
+		return nil
+	}
+	f.closed = true
+	writers := f.writers
+	f.writers = nil
+	f.mutex.Unlock()
+
+	var err error
+	for _, w := range writers {
+		if e := w.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	if e := f.File.Close(); e != nil && err == nil {
+		err = e
+	}
+	return err
 }
 
 func (f *File) Seek(offset int64, whence int) (int64, error) {
@@ -211,7 +286,7 @@ func Open(filepath string, mode int, gz FileType) (*File, error) {
 
 	file, err := os.OpenFile(filepath, mode, 0664)
 	if err == nil {
-		retfile = &File{filepath, file, mode, gz}
+		retfile = &File{Path: filepath, File: file, Mode: mode, Gz: gz}
 		if gz == GZ_UNKNOWN {
 			retfile.FixMode()
 		}
@@ -219,38 +294,49 @@ func Open(filepath string, mode int, gz FileType) (*File, error) {
 	return retfile, err
 }
 
-func ListFiles(fpath string, patterns []string) (matches []string, err error) {
-	_, err = os.Stat(fpath)
+// ListFiles returns the files below fpath whose names match patterns
+// (see GlobSet, so a leading ! excludes), in lexical order unless opts
+// say otherwise. The first path that can't be listed fails the listing,
+// unless an error handler is given with WithOnError. Dangling and looping
+// links are left out.
+func ListFiles(fs FileSystemInterface, fpath string, patterns []string, opts ...ListOption) (matches []string, err error) {
+	if _, err = statPath(fs, fpath); err != nil {
+		return nil, err
+	}
+	set, err := CompileGlobSet(patterns, false)
 	if err != nil {
 		return nil, err
 	}
 
-	visit := func(fp string, fi os.FileInfo, err error) error {
-		if err != nil {
-			fmt.Fprintln(os.Stderr, err)
-			return nil
-		}
-		if fi.IsDir() {
-			return nil
+	o := newListOptions(opts)
+	entries := make([]ListEntry, 0)
+	bad := badLinks{}
+	w := NewWalker(fs, fpath, &WalkOptions{
+		Sort:              true,
+		FollowSymlinks:    o.FollowSymlinks,
+		ReportLinkTargets: true,
+		OnError:           bad.handler(o.onError),
+	})
+	defer w.Close()
+	for w.Next() {
+		entry := w.Entry()
+		if !entry.Info.IsDir() && !bad[entry.Path] && set.Match(entry.Info.Name()) {
+			entries = append(entries, ListEntry{entry.Path, entry.Info})
 		}
-		var matched bool
-		for _, pattern := range patterns {
-			var m bool
-			m, err = filepath.Match(pattern, fi.Name())
-			if err != nil {
-				fmt.Fprintln(os.Stderr, err)
-				return err
-			}
-			matched = m || matched
-		}
-		if matched {
-			matches = append(matches, fp)
-		}
-		return nil
 	}
-	filepath.Walk(fpath, visit)
-	sort.Strings(matches)
-	return
+	if err = w.Err(); err != nil {
+		return nil, err
+	}
+	return sortedPaths(entries, o), nil
+}
+
+func sortedPaths(entries []ListEntry, o *ListOptions) []string {
+	o.sort(entries)
+	paths := make([]string, len(entries))
+	for idx, entry := range entries {
+		paths[idx] = entry.Path
+	}
+	return paths
 }
 
 func IsDir(path string) (bool, error) {
@@ -258,23 +344,78 @@ func IsDir(path string) (bool, error) {
 	return fileInfo.IsDir(), err
 }
 
-func ListDirs(fpath string, patterns []string) (matches []string, err error) {
-	var dirs []string
-	abs, _ := filepath.Abs(fpath)
+// badLinks collects dangling and looping links, so that listings can
+// leave them out, and passes every other error on to next
+type badLinks map[string]bool
+
+func (b badLinks) handler(next WalkErrorHandler) WalkErrorHandler {
+	return func(path string, err error) error {
+		var loop *SymlinkLoopError
+		var dangling *DanglingLinkError
+		if errors.As(err, &loop) || errors.As(err, &dangling) {
+			b[path] = true
+			return nil
+		}
+		return next(path, err)
+	}
+}
+
+// ListDirs returns the directories below fpath whose paths relative to
+// fpath match patterns (see GlobSet, so a leading ! excludes), in lexical
+// order unless opts say otherwise. A trailing slash on a pattern is
+// ignored. Dangling and looping links are left out. Local directories are
+// returned as absolute paths.
+func ListDirs(fs FileSystemInterface, fpath string, patterns []string, opts ...ListOption) (matches []string, err error) {
+	if fs == LocalFS {
+		if fpath, err = filepath.Abs(fpath); err != nil {
+			return nil, err
+		}
+	}
+	set, err := CompileGlobSet(patterns, false)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("Failed to glob: %v", err))
+	}
+	// Without **, there is no point descending deeper than the longest
+	// pattern
+	maxDepth := DepthLimit(0)
 	for _, pattern := range patterns {
-		globPattern := abs + "/" + pattern
-		if dirs, err = doublestar.Glob(globPattern); err != nil {
-			err = errors.New(fmt.Sprintf("Failed to glob: %v", err))
-			return
+		pattern = strings.TrimRight(pattern, "/")
+		if strings.Contains(pattern, "**") {
+			maxDepth = nil
+			break
+		} else if depth := strings.Count(pattern, "/") + 1; depth > *maxDepth {
+			*maxDepth = depth
 		}
-		for _, d := range dirs {
-			if ok, _ := IsDir(d); ok {
-				matches = append(matches, d)
-			}
+	}
+
+	o := newListOptions(opts)
+	entries := make([]ListEntry, 0)
+	bad := badLinks{}
+	w := NewWalker(fs, fpath, &WalkOptions{
+		MaxDepth:          maxDepth,
+		Sort:              true,
+		FollowSymlinks:    o.FollowSymlinks,
+		ReportLinkTargets: true,
+		OnError:           bad.handler(o.onError),
+	})
+	defer w.Close()
+	for w.Next() {
+		entry := w.Entry()
+		if !entry.Info.IsDir() || entry.Depth == 0 || bad[entry.Path] {
+			continue
 		}
+		rel, err := filepath.Rel(fpath, entry.Path)
+		if err != nil {
+			return nil, err
+		}
+		if set.Match(filepath.ToSlash(rel)) {
+			entries = append(entries, ListEntry{entry.Path, entry.Info})
+		}
+	}
+	if err = w.Err(); err != nil {
+		return nil, err
 	}
-	sort.Strings(matches)
-	return
+	return sortedPaths(entries, o), nil
 }
 
 func Exists(path string) bool {
@@ -304,7 +304,7 @@ func TestListFiles(t *testing.T) {
 	assert := assert.New(t)
 
 	// Test failure
-	_, err := ListFiles("/path/that/does/not/exist", []string{"*.gz", "*.txt"})
+	_, err := ListFiles(LocalFS, "/path/that/does/not/exist", []string{"*.gz", "*.txt"})
 	assert.NotNil(err, "Should have failed on non-existant path")
 
 	answer_txt := []string{"a.txt", "b.txt"}
@@ -318,7 +318,7 @@ func TestListFiles(t *testing.T) {
 	for i := range patterns {
 		p := patterns[i]
 		answer := answers[i]
-		files, err := ListFiles("test/list_files", p)
+		files, err := ListFiles(LocalFS, "test/list_files", p)
 		assert.Nil(err, "Failed to match")
 
 		trimmed := make([]string, len(files))
@@ -349,9 +349,10 @@ func TestListDirs(t *testing.T) {
 	for i := range patterns {
 		p := patterns[i]
 		answer := answers[i]
-		files, _ := ListDirs("./test/testdir", []string{p})
+		files, _ := ListDirs(LocalFS, "./test/testdir", []string{p})
 		trimmed := make([]string, len(files))
 		for idx, v := range files {
+			assert.True(filepath.IsAbs(v), v)
 			trimmed[idx] = path.Base(v)
 		}
 		//		fmt.Println("files:   %v", files)
@@ -495,3 +496,75 @@ func TestBufferedWriter(t *testing.T) {
 	}
 
 }
+
+func TestFileCloseClosesWriters(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	for _, fileType := range []FileType{GZ_TRUE, GZ_FALSE} {
+		fname := fmt.Sprintf("/tmp/file-close-writers-%v", fileType)
+		f, err := Open(fname, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileType)
+		require.Nil(err)
+		defer os.Remove(fname)
+
+		first, err := f.Writer(0)
+		require.Nil(err)
+		_, err = first.Write([]byte("Hello "))
+		require.Nil(err)
+		require.Nil(first.Close())
+		// Closing twice is fine
+		require.Nil(first.Close())
+		_, err = first.Write([]byte("lost"))
+		require.Equal(ErrWriterClosed, err)
+
+		// Never closed explicitly
+		second, err := f.Writer(16)
+		require.Nil(err)
+		_, err = second.Write([]byte("World\n"))
+		require.Nil(err)
+
+		require.Nil(f.Close())
+		require.Nil(f.Close())
+		_, err = f.Writer(0)
+		require.NotNil(err)
+
+		f, err = Open(fname, os.O_RDONLY, fileType)
+		require.Nil(err)
+		success, err := CheckFileContentsMatch(f, []byte("Hello World\n"), true, 0)
+		require.Nil(err)
+		require.True(success)
+		f.Close()
+	}
+}
+
+func TestLayeredWriter(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fname := "/tmp/layered-writer.gz"
+	defer os.Remove(fname)
+	data := RandomData(100 * 1024)
+	enc := &Encryption{KeyID: "test-key", Keys: testKeys}
+	hasher := NewHasher(HASH_SHA256)
+
+	f, err := Open(fname, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, GZ_TRUE)
+	require.Nil(err)
+	w, err := f.LayeredWriter(1024, HashLayer(hasher), GzipLayer(9), EncryptLayer(enc))
+	require.Nil(err)
+	_, err = w.Write(data)
+	require.Nil(err)
+	// Every layer knows how to flush
+	require.Nil(w.Flush())
+	require.Nil(f.Close())
+
+	f, err = Open(fname, os.O_RDONLY, GZ_TRUE)
+	require.Nil(err)
+	defer f.Close()
+	reader, err := f.DecryptedRawReader(testKeys)
+	require.Nil(err)
+	got := bytes.NewBuffer(nil)
+	_, err = io.Copy(got, reader)
+	require.Nil(err)
+	require.Equal(data, got.Bytes())
+	require.Equal(int64(len(data)), hasher.Size())
+}
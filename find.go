@@ -0,0 +1,260 @@
+package easyfiles
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// FindEntry is the entry a Predicate is tested against
+type FindEntry struct {
+	WalkEntry
+	// Rel is Path relative to the root of the search, slash-separated.
+	// It is "." for the root itself.
+	Rel string
+
+	fs    FileSystemInterface
+	prune bool
+}
+
+// Prune stops Find from descending into the entry
+func (e *FindEntry) Prune() {
+	e.prune = true
+}
+
+// Predicate decides whether an entry is part of Find's results.
+// Predicates are evaluated in order and evaluation stops at the first one
+// that fails, as with find(1), so put pruning predicates like MaxDepth
+// and Prune first.
+type Predicate func(e *FindEntry) bool
+
+// Find walks root in lexical order and returns every entry, root
+// included, that satisfies all of predicates. The walk stops at the first
+// error, which is returned along with the entries found so far.
+func Find(fs FileSystemInterface, root string, predicates ...Predicate) ([]WalkEntry, error) {
+	w := NewWalker(fs, root, &WalkOptions{Sort: true})
+	defer w.Close()
+
+	matches := make([]WalkEntry, 0)
+	for w.Next() {
+		entry := w.Entry()
+		rel, err := filepath.Rel(root, entry.Path)
+		if err != nil {
+			return matches, err
+		}
+		e := &FindEntry{WalkEntry: entry, Rel: filepath.ToSlash(rel), fs: fs}
+		if AllOf(predicates...)(e) {
+			matches = append(matches, entry)
+		}
+		if e.prune {
+			w.SkipDir()
+		}
+	}
+	return matches, w.Err()
+}
+
+// AllOf is satisfied when all of predicates are
+func AllOf(predicates ...Predicate) Predicate {
+	return func(e *FindEntry) bool {
+		for _, p := range predicates {
+			if !p(e) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// AnyOf is satisfied when any of predicates is
+func AnyOf(predicates ...Predicate) Predicate {
+	return func(e *FindEntry) bool {
+		for _, p := range predicates {
+			if p(e) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Not is satisfied when p isn't
+func Not(p Predicate) Predicate {
+	return func(e *FindEntry) bool {
+		return !p(e)
+	}
+}
+
+// Prune excludes entries satisfying p from the results and, for
+// directories, everything below them
+func Prune(p Predicate) Predicate {
+	return func(e *FindEntry) bool {
+		if p(e) {
+			e.Prune()
+			return false
+		}
+		return true
+	}
+}
+
+// NameGlob matches the base name against pattern (see Glob)
+func NameGlob(pattern string) (Predicate, error) {
+	g, err := CompileGlob(pattern, false)
+	if err != nil {
+		return nil, err
+	}
+	return func(e *FindEntry) bool {
+		return g.Match(e.Info.Name())
+	}, nil
+}
+
+// MustNameGlob is NameGlob for patterns known to be good. It panics if
+// pattern is bad.
+func MustNameGlob(pattern string) Predicate {
+	p, err := NameGlob(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("Bad glob pattern %q: %v", pattern, err))
+	}
+	return p
+}
+
+// PathGlob matches the path relative to the root against pattern (see
+// Glob)
+func PathGlob(pattern string) (Predicate, error) {
+	g, err := CompileGlob(pattern, false)
+	if err != nil {
+		return nil, err
+	}
+	return func(e *FindEntry) bool {
+		return g.Match(e.Rel)
+	}, nil
+}
+
+// MustPathGlob is PathGlob for patterns known to be good. It panics if
+// pattern is bad.
+func MustPathGlob(pattern string) Predicate {
+	p, err := PathGlob(pattern)
+	if err != nil {
+		panic(fmt.Sprintf("Bad glob pattern %q: %v", pattern, err))
+	}
+	return p
+}
+
+// NameRegexp matches the base name against re
+func NameRegexp(re *regexp.Regexp) Predicate {
+	return func(e *FindEntry) bool {
+		return re.MatchString(e.Info.Name())
+	}
+}
+
+// PathRegexp matches the path relative to the root against re
+func PathRegexp(re *regexp.Regexp) Predicate {
+	return func(e *FindEntry) bool {
+		return re.MatchString(e.Rel)
+	}
+}
+
+// SizeAtLeast is satisfied by regular files of at least size bytes
+func SizeAtLeast(size int64) Predicate {
+	return func(e *FindEntry) bool {
+		return e.Info.Mode().IsRegular() && e.Info.Size() >= size
+	}
+}
+
+// SizeAtMost is satisfied by regular files of at most size bytes
+func SizeAtMost(size int64) Predicate {
+	return func(e *FindEntry) bool {
+		return e.Info.Mode().IsRegular() && e.Info.Size() <= size
+	}
+}
+
+// ModifiedAfter is satisfied by entries modified after t
+func ModifiedAfter(t time.Time) Predicate {
+	return func(e *FindEntry) bool {
+		return e.Info.ModTime().After(t)
+	}
+}
+
+// ModifiedBefore is satisfied by entries modified before t
+func ModifiedBefore(t time.Time) Predicate {
+	return func(e *FindEntry) bool {
+		return e.Info.ModTime().Before(t)
+	}
+}
+
+// TypeRegular is satisfied by regular files
+func TypeRegular() Predicate {
+	return func(e *FindEntry) bool {
+		return e.Info.Mode().IsRegular()
+	}
+}
+
+// TypeDir is satisfied by directories
+func TypeDir() Predicate {
+	return func(e *FindEntry) bool {
+		return e.Info.IsDir()
+	}
+}
+
+// TypeSymlink is satisfied by symbolic links. Find doesn't follow them.
+func TypeSymlink() Predicate {
+	return func(e *FindEntry) bool {
+		return e.Info.Mode()&os.ModeSymlink != 0
+	}
+}
+
+// Empty is satisfied by empty regular files and empty directories
+func Empty() Predicate {
+	return func(e *FindEntry) bool {
+		switch {
+		case e.Info.Mode().IsRegular():
+			return e.Info.Size() == 0
+		case e.Info.IsDir():
+			infos, err := e.fs.ReadDir(e.Path)
+			return err == nil && len(infos) == 0
+		}
+		return false
+	}
+}
+
+// Compressed is satisfied by regular files whose contents are (GZ_TRUE)
+// or aren't (GZ_FALSE) gzip compressed, regardless of their names. Files
+// that can't be read satisfy neither.
+func Compressed(gz FileType) Predicate {
+	return func(e *FindEntry) bool {
+		if !e.Info.Mode().IsRegular() {
+			return false
+		}
+		f, err := e.fs.Open(e.Path, os.O_RDONLY, GZ_FALSE)
+		if err != nil {
+			return false
+		}
+		defer f.Close()
+		detected := GZ_FALSE
+		if reader, err := gzip.NewReader(f.File); err == nil {
+			reader.Close()
+			detected = GZ_TRUE
+		}
+		return detected == gz
+	}
+}
+
+// MinDepth is satisfied by entries at least depth levels below the root
+func MinDepth(depth int) Predicate {
+	return func(e *FindEntry) bool {
+		return e.Depth >= depth
+	}
+}
+
+// MaxDepth is satisfied by entries at most depth levels below the root,
+// and stops Find from descending any further
+func MaxDepth(depth int) Predicate {
+	return func(e *FindEntry) bool {
+		if e.Depth >= depth {
+			e.Prune()
+		}
+		return e.Depth <= depth
+	}
+}
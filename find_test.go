@@ -0,0 +1,86 @@
+package easyfiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func findRel(require *require.Assertions, fs FileSystemInterface, root string, predicates ...Predicate) []string {
+	entries, err := Find(fs, root, predicates...)
+	require.Nil(err)
+	rel := make([]string, len(entries))
+	for idx, entry := range entries {
+		rel[idx], _ = filepath.Rel(root, entry.Path)
+		require.NotNil(entry.Info)
+	}
+	return rel
+}
+
+func TestFind(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fs := NewMemFS()
+	makeWalkTree(require, fs, "/root")
+	buf := bytes.NewBuffer(nil)
+	gz := gzip.NewWriter(buf)
+	gz.Write([]byte("compressed"))
+	gz.Close()
+	// Compression is detected, not guessed from the name
+	require.Nil(fs.WriteFile("/root/b/data", buf.Bytes(), 0664))
+	require.Nil(fs.WriteFile("/root/b/empty", nil, 0664))
+	old := time.Now().Add(-time.Hour)
+	require.Nil(fs.Chtimes("/root/a.txt", old, old))
+
+	find := func(predicates ...Predicate) []string {
+		return findRel(require, fs, "/root", predicates...)
+	}
+
+	require.Equal([]string{"a/1/y.txt", "a.txt", "b/x.txt"}, find(MustNameGlob("*.txt")))
+	require.Equal([]string{"a/1", "a/1/y.txt", "a/2", "a/2/z.gz"}, find(MustPathGlob("a/**/*")))
+	require.Equal([]string{"a/1", "a/2"}, find(NameRegexp(regexp.MustCompile(`^\d$`))))
+	require.Equal([]string{"a/1/y.txt", "a/2/z.gz"}, find(TypeRegular(), PathRegexp(regexp.MustCompile(`^a/`))))
+	require.Equal([]string{".", "a", "a/1", "a/2", "b", "c"}, find(TypeDir()))
+
+	require.Equal([]string{"b/data"}, find(Compressed(GZ_TRUE)))
+	require.Equal([]string{"b/empty", "c"}, find(Empty()))
+	require.Equal([]string{"b/data"}, find(SizeAtLeast(10)))
+	require.Equal([]string{"b/empty"}, find(SizeAtMost(0)))
+	require.Equal([]string{"a.txt"}, find(TypeRegular(), ModifiedBefore(time.Now().Add(-time.Minute))))
+	require.Equal(5, len(find(TypeRegular(), ModifiedAfter(time.Now().Add(-time.Minute)))))
+
+	// Depth limits and pruning
+	require.Equal([]string{"a", "a.txt", "b", "c"}, find(MaxDepth(1), MinDepth(1)))
+	require.Equal([]string{"a.txt", "b/data", "b/empty", "b/x.txt"}, find(Prune(MustPathGlob("a")), TypeRegular()))
+	require.Equal([]string{"a.txt", "b/x.txt"}, find(TypeRegular(), AnyOf(MustNameGlob("a*"), MustNameGlob("x*"))))
+	require.Equal([]string{"a/2/z.gz", "b/data", "b/empty"}, find(TypeRegular(), Not(MustNameGlob("*.txt"))))
+
+	// Bad patterns are errors, not predicates that match nothing
+	_, err := NameGlob("*.{gz")
+	require.NotNil(err)
+	_, err = PathGlob("a/[")
+	require.NotNil(err)
+	require.Panics(func() { MustNameGlob("*.{gz") })
+
+	_, err = Find(fs, "/missing")
+	require.True(os.IsNotExist(err))
+}
+
+func TestFindSymlinks(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir := t.TempDir()
+	makeWalkTree(require, LocalFS, dir)
+	require.Nil(Symlink(LocalFS, filepath.Join(dir, "a"), filepath.Join(dir, "link")))
+	// Links aren't followed
+	require.Equal([]string{"link"}, findRel(require, LocalFS, dir, TypeSymlink()))
+	require.Equal(3, len(findRel(require, LocalFS, dir, MustNameGlob("*.txt"))))
+}
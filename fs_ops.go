@@ -0,0 +1,123 @@
+package easyfiles
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var (
+	// ErrNotSupported is returned when a filesystem lacks an optional
+	// capability
+	ErrNotSupported = errors.New("Operation not supported by filesystem")
+	// ErrSameFile is returned when copying a file onto itself, which would
+	// otherwise truncate it before it is read
+	ErrSameFile = errors.New("Source and destination are the same file")
+)
+
+// Rename renames oldpath to newpath on fs. See Renamer for the semantics.
+func Rename(fs FileSystemInterface, oldpath, newpath string) error {
+	r, ok := fs.(Renamer)
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: ErrNotSupported}
+	}
+	return r.Rename(oldpath, newpath)
+}
+
+// Copy copies the bytes stored at src to dst, replacing any file at dst.
+// Nothing is decompressed or recompressed. Filesystems that implement
+// Copier copy natively; otherwise the data is streamed through the client
+// and, where Chmod is supported, dst is given the permission bits of src.
+func Copy(fs FileSystemInterface, src, dst string) error {
+	if c, ok := fs.(Copier); ok {
+		return c.Copy(src, dst)
+	}
+	info, err := fs.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info != nil && info.IsDir() {
+		return &os.PathError{Op: "copy", Path: src, Err: errors.New("is a directory")}
+	}
+	if filepath.Clean(src) == filepath.Clean(dst) || sameFile(info, fs, dst) {
+		return &os.LinkError{Op: "copy", Old: src, New: dst, Err: ErrSameFile}
+	}
+
+	in, err := fs.Open(src, os.O_RDONLY, GZ_FALSE)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := fs.Open(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, GZ_FALSE)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out.File, in.File)
+	if e := out.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	if c, ok := fs.(Chmoder); ok && info != nil {
+		return c.Chmod(dst, info.Mode().Perm())
+	}
+	return nil
+}
+
+// sameFile reports whether dst on fs is the file srcInfo describes. Only
+// files whose FileInfos os.SameFile understands can be told apart this way.
+func sameFile(srcInfo os.FileInfo, fs FileSystemInterface, dst string) bool {
+	if srcInfo == nil {
+		return false
+	}
+	dstInfo, err := fs.Stat(dst)
+	return err == nil && dstInfo != nil && os.SameFile(srcInfo, dstInfo)
+}
+
+// Chmod changes the permission bits of name on fs
+func Chmod(fs FileSystemInterface, name string, mode os.FileMode) error {
+	c, ok := fs.(Chmoder)
+	if !ok {
+		return &os.PathError{Op: "chmod", Path: name, Err: ErrNotSupported}
+	}
+	return c.Chmod(name, mode)
+}
+
+// Chtimes changes the access and modification times of name on fs
+func Chtimes(fs FileSystemInterface, name string, atime time.Time, mtime time.Time) error {
+	c, ok := fs.(Chtimeser)
+	if !ok {
+		return &os.PathError{Op: "chtimes", Path: name, Err: ErrNotSupported}
+	}
+	return c.Chtimes(name, atime, mtime)
+}
+
+// Truncate changes the size of name on fs
+func Truncate(fs FileSystemInterface, name string, size int64) error {
+	t, ok := fs.(Truncater)
+	if !ok {
+		return &os.PathError{Op: "truncate", Path: name, Err: ErrNotSupported}
+	}
+	return t.Truncate(name, size)
+}
+
+// Symlink creates newname as a symbolic link to oldname on fs
+func Symlink(fs FileSystemInterface, oldname, newname string) error {
+	s, ok := fs.(Symlinker)
+	if !ok {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: ErrNotSupported}
+	}
+	return s.Symlink(oldname, newname)
+}
+
+// Readlink returns the target of the symbolic link name on fs
+func Readlink(fs FileSystemInterface, name string) (string, error) {
+	s, ok := fs.(Symlinker)
+	if !ok {
+		return "", &os.PathError{Op: "readlink", Path: name, Err: ErrNotSupported}
+	}
+	return s.Readlink(name)
+}
@@ -0,0 +1,85 @@
+package easyfiles
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCopyFallback(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// ChecksumFileSystem has no Copier, so the data is streamed and the
+	// copy gets a sidecar of its own
+	fs := NewChecksumFileSystem(NewMemFS(), 0)
+	require.Nil(fs.WriteFile("/src", []byte("hello"), 0600))
+	require.Nil(Copy(fs, "/src", "/dst"))
+	b, err := fs.ReadFile("/dst")
+	require.Nil(err)
+	require.Equal("hello", string(b))
+	info, err := fs.Stat("/dst")
+	require.Nil(err)
+	require.Equal("-rw-------", info.Mode().String())
+	exists, err := fs.Exists(ChecksumPath("/dst"))
+	require.Nil(err)
+	require.True(exists)
+}
+
+func TestCopySameFile(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "f")
+	require.Nil(LocalFS.WriteFile(name, []byte("hello"), 0664))
+	require.Nil(Symlink(LocalFS, name, filepath.Join(dir, "link")))
+
+	// Neither the same path, nor another path to the same file, nor the
+	// same file through two mounts may empty it
+	m := NewMountFS()
+	require.Nil(m.Mount("/a", LocalFS, dir))
+	require.Nil(m.Mount("/b", LocalFS, dir))
+	for _, copy := range []func() error{
+		func() error { return Copy(LocalFS, name, name) },
+		func() error { return Copy(LocalFS, name, filepath.Join(dir, ".", "f")) },
+		func() error { return Copy(LocalFS, name, filepath.Join(dir, "link")) },
+		func() error { return Copy(m, "/a/f", "/b/f") },
+	} {
+		require.True(errors.Is(copy(), ErrSameFile))
+		b, err := LocalFS.ReadFile(name)
+		require.Nil(err)
+		require.Equal("hello", string(b))
+	}
+}
+
+func TestMemFSTruncate(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fs := NewMemFS()
+	require.Nil(fs.WriteFile("/f", []byte("hello"), 0664))
+	require.Nil(Truncate(fs, "/f", 2))
+	require.Nil(Truncate(fs, "/f", 4))
+	b, err := fs.ReadFile("/f")
+	require.Nil(err)
+	require.Equal("he\x00\x00", string(b))
+}
+
+func TestNotSupported(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fs := FromIOFS(fstest.MapFS{"a": {Data: []byte("a")}})
+	require.True(errors.Is(Rename(fs, "a", "b"), ErrNotSupported))
+	require.True(errors.Is(Chmod(fs, "a", 0600), ErrNotSupported))
+	require.True(errors.Is(Truncate(fs, "a", 0), ErrNotSupported))
+	require.True(errors.Is(Symlink(fs, "a", "b"), ErrNotSupported))
+	_, err := Readlink(fs, "a")
+	require.True(errors.Is(err, ErrNotSupported))
+	// Copy needs to write, which a read-only filesystem can't do
+	require.True(errors.Is(Copy(fs, "a", "b"), ErrReadOnly))
+}
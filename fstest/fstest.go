@@ -0,0 +1,561 @@
+// Package fstest checks that a FileSystemInterface implementation behaves
+// the way the rest of easyfiles expects, which is to say like the local
+// filesystem. Third-party backends can run TestFS from their own tests to
+// certify themselves.
+package fstest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gurupras/go-easyfiles"
+)
+
+// Options describes the filesystem under test
+type Options struct {
+	// Dir is a directory the suite may create files under. Each test
+	// works in its own subdirectory of Dir and removes it when done.
+	Dir string
+	// AppendOnly marks filesystems (like HDFS) that can only write at the
+	// end of a file. Checks that overwrite a file in place are skipped.
+	AppendOnly bool
+	// LooseErrors marks filesystems that don't report errors the way the
+	// os package does. Failing operations only need to return some error,
+	// Stat may report a missing file as a nil FileInfo, and the checks
+	// that a handle refuses operations its open mode doesn't allow are
+	// skipped.
+	LooseErrors bool
+	// Concurrency is the number of goroutines used by the concurrency
+	// test. A value of 0 uses 8.
+	Concurrency int
+}
+
+// TestFS runs the whole suite against fs, each part as a subtest
+func TestFS(t *testing.T, fs easyfiles.FileSystemInterface, opts Options) {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 8
+	}
+	tests := []struct {
+		name string
+		fn   func(*testing.T, *suite)
+	}{
+		{"OpenModes", testOpenModes},
+		{"TruncateAppend", testTruncateAppend},
+		{"Errors", testErrors},
+		{"Gzip", testGzip},
+		{"GlobReadDir", testGlobReadDir},
+		{"Concurrency", testConcurrency},
+		{"Operations", testOperations},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			s := &suite{fs, opts, path.Join(opts.Dir, "fstest-"+test.name)}
+			if err := fs.RemoveAll(s.dir); err != nil {
+				t.Fatalf("RemoveAll(%q): %v", s.dir, err)
+			}
+			if err := fs.Makedirs(s.dir); err != nil {
+				t.Fatalf("Makedirs(%q): %v", s.dir, err)
+			}
+			defer fs.RemoveAll(s.dir)
+			test.fn(t, s)
+		})
+	}
+}
+
+type suite struct {
+	fs   easyfiles.FileSystemInterface
+	opts Options
+	dir  string
+}
+
+func (s *suite) path(elem ...string) string {
+	return path.Join(append([]string{s.dir}, elem...)...)
+}
+
+// writeFile writes text through a file opened with flag and returns the
+// file's contents afterwards
+func (s *suite) writeFile(t *testing.T, name string, flag int, text string) string {
+	f, err := s.fs.Open(name, flag, easyfiles.GZ_FALSE)
+	if err != nil {
+		t.Fatalf("Open(%q, %#x): %v", name, flag, err)
+	}
+	if _, err := io.WriteString(f.File, text); err != nil {
+		t.Fatalf("WriteString(%q): %v", name, err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatalf("Close(%q): %v", name, err)
+	}
+	data, err := s.fs.ReadFile(name)
+	if err != nil {
+		t.Fatalf("ReadFile(%q): %v", name, err)
+	}
+	return string(data)
+}
+
+// checkError checks that err is an error of the given kind. Under
+// LooseErrors any non-nil error will do.
+func (s *suite) checkError(t *testing.T, what string, err error, kind error) {
+	t.Helper()
+	switch {
+	case err == nil:
+		t.Errorf("%v succeeded", what)
+	case s.opts.LooseErrors:
+	case !errors.Is(err, kind):
+		t.Errorf("%v: got %v, want %v", what, err, kind)
+	}
+}
+
+// checkMissing checks that Stat reports name as missing
+func (s *suite) checkMissing(t *testing.T, name string) {
+	t.Helper()
+	info, err := s.fs.Stat(name)
+	if s.opts.LooseErrors && err == nil && info == nil {
+		return
+	}
+	s.checkError(t, fmt.Sprintf("Stat(%q)", name), err, os.ErrNotExist)
+}
+
+func testOpenModes(t *testing.T, s *suite) {
+	name := s.path("modes")
+
+	_, err := s.fs.Open(name, os.O_RDONLY, easyfiles.GZ_FALSE)
+	s.checkError(t, "Open of missing file", err, os.ErrNotExist)
+
+	f, err := s.fs.Open(name, os.O_CREATE|os.O_WRONLY, easyfiles.GZ_FALSE)
+	if err != nil {
+		t.Fatalf("Open with O_CREATE: %v", err)
+	}
+	if _, err := f.File.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if !s.opts.LooseErrors {
+		if _, err := f.File.Read(make([]byte, 1)); err == nil {
+			t.Errorf("Read of write-only file succeeded")
+		}
+	}
+	f.Close()
+
+	info, err := s.fs.Stat(name)
+	if err != nil || info == nil {
+		t.Fatalf("Stat after create: %v, %v", info, err)
+	}
+	if info.IsDir() || info.Size() != 5 || info.Name() != "modes" {
+		t.Errorf("Stat after create: name=%q dir=%v size=%v", info.Name(), info.IsDir(), info.Size())
+	}
+	if exists, err := s.fs.Exists(name); err != nil || !exists {
+		t.Errorf("Exists(%q) = %v, %v", name, exists, err)
+	}
+
+	_, err = s.fs.Open(name, os.O_CREATE|os.O_EXCL|os.O_WRONLY, easyfiles.GZ_FALSE)
+	s.checkError(t, "Open with O_EXCL of existing file", err, os.ErrExist)
+
+	f, err = s.fs.Open(name, os.O_RDONLY, easyfiles.GZ_FALSE)
+	if err != nil {
+		t.Fatalf("Open read-only: %v", err)
+	}
+	b := make([]byte, 16)
+	n, err := io.ReadFull(f.File, b)
+	if err != io.ErrUnexpectedEOF || string(b[:n]) != "hello" {
+		t.Errorf("Read: have %q, %v want %q", b[:n], err, "hello")
+	}
+	if off, err := f.Seek(1, io.SeekStart); err != nil || off != 1 {
+		t.Errorf("Seek: %v, %v", off, err)
+	}
+	n, _ = io.ReadFull(f.File, b)
+	if string(b[:n]) != "ello" {
+		t.Errorf("Read after Seek: have %q want %q", b[:n], "ello")
+	}
+	if !s.opts.LooseErrors {
+		if _, err := f.File.Write([]byte("x")); err == nil {
+			t.Errorf("Write to read-only file succeeded")
+		}
+	}
+	f.Close()
+
+	if exists, err := s.fs.Exists(s.path("missing")); err != nil || exists {
+		t.Errorf("Exists of missing file = %v, %v", exists, err)
+	}
+}
+
+func testTruncateAppend(t *testing.T, s *suite) {
+	name := s.path("append.txt")
+	check := func(what, have, want string) {
+		t.Helper()
+		if have != want {
+			t.Fatalf("%v: have %q want %q", what, have, want)
+		}
+	}
+
+	check("create", s.writeFile(t, name, os.O_CREATE|os.O_TRUNC|os.O_RDWR, "new"), "new")
+	check("append", s.writeFile(t, name, os.O_APPEND|os.O_RDWR, "|append"), "new|append")
+	check("create+append", s.writeFile(t, name, os.O_CREATE|os.O_APPEND|os.O_RDWR, "|append"), "new|append|append")
+	if err := s.fs.Remove(name); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	check("append to new file", s.writeFile(t, name, os.O_CREATE|os.O_APPEND|os.O_RDWR, "new&append"), "new&append")
+	if !s.opts.AppendOnly {
+		check("overwrite", s.writeFile(t, name, os.O_CREATE|os.O_RDWR, "old"), "old&append")
+	}
+	check("truncate", s.writeFile(t, name, os.O_CREATE|os.O_TRUNC|os.O_RDWR, "new"), "new")
+
+	if err := s.fs.WriteFile(name, []byte("replaced"), 0664); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	data, err := s.fs.ReadFile(name)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	check("WriteFile", string(data), "replaced")
+}
+
+func testErrors(t *testing.T, s *suite) {
+	file := s.path("file")
+	if err := s.fs.WriteFile(file, []byte("data"), 0664); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	missing := s.path("no-such-file")
+
+	_, err := s.fs.Open(missing, os.O_RDONLY, easyfiles.GZ_FALSE)
+	s.checkError(t, "Open of missing file", err, os.ErrNotExist)
+	_, err = s.fs.Open(s.dir, os.O_WRONLY, easyfiles.GZ_FALSE)
+	s.checkError(t, "Open of directory for writing", err, syscall.EISDIR)
+	_, err = s.fs.Open(path.Join(file, "no-such-file"), os.O_WRONLY|os.O_CREATE, easyfiles.GZ_FALSE)
+	s.checkError(t, "Open below a file", err, syscall.ENOTDIR)
+	if f, err := s.fs.Open("", os.O_RDONLY, easyfiles.GZ_FALSE); err == nil {
+		f.Close()
+		t.Errorf(`Open("") succeeded`)
+	}
+
+	s.checkMissing(t, missing)
+	_, err = s.fs.ReadFile(missing)
+	s.checkError(t, "ReadFile of missing file", err, os.ErrNotExist)
+	err = s.fs.Remove(missing)
+	s.checkError(t, "Remove of missing file", err, os.ErrNotExist)
+	_, err = s.fs.ReadDir(missing)
+	s.checkError(t, "ReadDir of missing directory", err, os.ErrNotExist)
+	_, err = s.fs.ReadDir(file)
+	s.checkError(t, "ReadDir of file", err, syscall.ENOTDIR)
+
+	if err := s.fs.Makedirs(s.path("a", "b")); err != nil {
+		t.Fatalf("Makedirs: %v", err)
+	}
+	if err := s.fs.Remove(s.path("a")); err == nil {
+		t.Errorf("Remove of non-empty directory succeeded")
+	}
+	if err := s.fs.RemoveAll(s.path("a")); err != nil {
+		t.Errorf("RemoveAll: %v", err)
+	}
+	s.checkMissing(t, s.path("a", "b"))
+	if err := s.fs.RemoveAll(missing); err != nil {
+		t.Errorf("RemoveAll of missing file: %v", err)
+	}
+}
+
+func testGzip(t *testing.T, s *suite) {
+	lines := make([]string, 1000)
+	for idx := range lines {
+		lines[idx] = fmt.Sprintf("line %d", idx)
+	}
+	write := func(name string, gz easyfiles.FileType) {
+		f, err := s.fs.Open(name, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, gz)
+		if err != nil {
+			t.Fatalf("Open(%q): %v", name, err)
+		}
+		if f.Gz != easyfiles.GZ_TRUE {
+			t.Fatalf("Open(%q): file is %v", name, f.Gz)
+		}
+		w, err := f.Writer(0)
+		if err != nil {
+			t.Fatalf("Writer: %v", err)
+		}
+		for _, line := range lines {
+			w.Write([]byte(line + "\n"))
+		}
+		if err := f.Close(); err != nil {
+			t.Fatalf("Close(%q): %v", name, err)
+		}
+	}
+	check := func(name string) {
+		f, err := s.fs.Open(name, os.O_RDONLY, easyfiles.GZ_UNKNOWN)
+		if err != nil {
+			t.Fatalf("Open(%q): %v", name, err)
+		}
+		defer f.Close()
+		if f.Gz != easyfiles.GZ_TRUE {
+			t.Fatalf("Open(%q): compression not detected", name)
+		}
+		scanner, err := f.Reader(0)
+		if err != nil {
+			t.Fatalf("Reader: %v", err)
+		}
+		idx := 0
+		for scanner.Scan() {
+			if idx < len(lines) && scanner.Text() != lines[idx] {
+				t.Fatalf("%v line %d: have %q want %q", name, idx, scanner.Text(), lines[idx])
+			}
+			idx++
+		}
+		if idx != len(lines) {
+			t.Fatalf("%v: have %d lines want %d", name, idx, len(lines))
+		}
+	}
+
+	// Detected from the suffix on write and from the contents on read
+	write(s.path("out.gz"), easyfiles.GZ_UNKNOWN)
+	check(s.path("out.gz"))
+	write(s.path("out.data"), easyfiles.GZ_TRUE)
+	check(s.path("out.data"))
+
+	data, err := s.fs.ReadFile(s.path("out.data"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if !bytes.HasPrefix(data, []byte{0x1f, 0x8b}) {
+		t.Errorf("Stored data is not gzip")
+	}
+
+	name := s.path("plain")
+	if err := s.fs.WriteFile(name, []byte("not compressed\n"), 0664); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	f, err := s.fs.Open(name, os.O_RDONLY, easyfiles.GZ_UNKNOWN)
+	if err != nil {
+		t.Fatalf("Open(%q): %v", name, err)
+	}
+	defer f.Close()
+	if f.Gz != easyfiles.GZ_FALSE {
+		t.Errorf("Open(%q): plain file detected as %v", name, f.Gz)
+	}
+	b := make([]byte, 3)
+	if _, err := io.ReadFull(f.File, b); err != nil || string(b) != "not" {
+		t.Errorf("Detection did not rewind the file: have %q, %v", b, err)
+	}
+}
+
+func testGlobReadDir(t *testing.T, s *suite) {
+	files := []string{"a.txt", "b.log", "c.txt", "sub/d.txt", "sub/deeper/e.txt"}
+	for _, name := range files {
+		p := s.path(name)
+		if err := s.fs.Makedirs(path.Dir(p)); err != nil {
+			t.Fatalf("Makedirs: %v", err)
+		}
+		if err := s.fs.WriteFile(p, []byte(name), 0664); err != nil {
+			t.Fatalf("WriteFile(%q): %v", p, err)
+		}
+	}
+
+	infos, err := s.fs.ReadDir(s.dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	names := make([]string, 0)
+	for _, info := range infos {
+		names = append(names, info.Name())
+		stat, err := s.fs.Stat(s.path(info.Name()))
+		if err != nil || stat == nil {
+			t.Errorf("Stat(%q): %v", info.Name(), err)
+			continue
+		}
+		if stat.IsDir() != info.IsDir() || (!info.IsDir() && stat.Size() != info.Size()) {
+			t.Errorf("Stat(%q) disagrees with ReadDir", info.Name())
+		}
+	}
+	if !sort.StringsAreSorted(names) {
+		t.Errorf("ReadDir is not sorted: %v", names)
+	}
+	checkStrings(t, "ReadDir", names, []string{"a.txt", "b.log", "c.txt", "sub"})
+
+	glob := func(pattern string, want ...string) {
+		t.Helper()
+		matches, err := s.fs.Glob(s.path(pattern))
+		if err != nil {
+			t.Errorf("Glob(%q): %v", pattern, err)
+			return
+		}
+		for idx := range want {
+			want[idx] = s.path(want[idx])
+		}
+		sort.Strings(matches)
+		checkStrings(t, fmt.Sprintf("Glob(%q)", pattern), matches, want)
+	}
+	glob("*", "a.txt", "b.log", "c.txt", "sub")
+	glob("*.txt", "a.txt", "c.txt")
+	glob("[ab].*", "a.txt", "b.log")
+	glob("**/*.txt", "a.txt", "c.txt", "sub/d.txt", "sub/deeper/e.txt")
+	glob("sub/**/e.txt", "sub/deeper/e.txt")
+	glob("nothing*")
+}
+
+func testConcurrency(t *testing.T, s *suite) {
+	n := s.opts.Concurrency
+	wg := sync.WaitGroup{}
+	for idx := 0; idx < n; idx++ {
+		wg.Add(1)
+		go func(idx int) {
+			defer wg.Done()
+			name := s.path(fmt.Sprintf("file-%03d", idx))
+			data := bytes.Repeat([]byte(fmt.Sprintf("%d\n", idx)), 1000)
+			if err := s.fs.WriteFile(name, data, 0664); err != nil {
+				t.Errorf("WriteFile(%q): %v", name, err)
+				return
+			}
+			if _, err := s.fs.ReadDir(s.dir); err != nil {
+				t.Errorf("ReadDir: %v", err)
+			}
+			if _, err := s.fs.Glob(s.path("file-*")); err != nil {
+				t.Errorf("Glob: %v", err)
+			}
+			got, err := s.fs.ReadFile(name)
+			if err != nil || !bytes.Equal(got, data) {
+				t.Errorf("ReadFile(%q): contents differ (%v)", name, err)
+			}
+		}(idx)
+	}
+	wg.Wait()
+
+	infos, err := s.fs.ReadDir(s.dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(infos) != n {
+		t.Errorf("ReadDir: have %d entries want %d", len(infos), n)
+	}
+	matches, err := s.fs.Glob(s.path("file-*"))
+	if err != nil {
+		t.Fatalf("Glob: %v", err)
+	}
+	if len(matches) != n {
+		t.Errorf("Glob: have %d matches want %d", len(matches), n)
+	}
+}
+
+// supported reports whether err is something other than ErrNotSupported
+func supported(t *testing.T, what string, err error) bool {
+	t.Helper()
+	if errors.Is(err, easyfiles.ErrNotSupported) {
+		t.Logf("%v not supported", what)
+		return false
+	}
+	return true
+}
+
+// testOperations checks the optional capabilities the filesystem has
+func testOperations(t *testing.T, s *suite) {
+	read := func(name string) string {
+		t.Helper()
+		b, err := s.fs.ReadFile(name)
+		if err != nil {
+			t.Fatalf("ReadFile(%q): %v", name, err)
+		}
+		return string(b)
+	}
+	src := s.path("src")
+	if err := s.fs.WriteFile(src, []byte("hello"), 0664); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := s.fs.Makedirs(s.path("dir")); err != nil {
+		t.Fatalf("Makedirs: %v", err)
+	}
+
+	// Copy is always available, natively or not
+	dst := s.path("dir", "copy")
+	if err := s.fs.WriteFile(dst, []byte("overwritten"), 0664); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := easyfiles.Copy(s.fs, src, dst); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if data := read(dst); data != "hello" {
+		t.Errorf("Copy: have %q want %q", data, "hello")
+	}
+	if err := easyfiles.Copy(s.fs, s.path("dir"), s.path("dircopy")); err == nil {
+		t.Errorf("Copy of a directory succeeded")
+	}
+
+	// Renames move across directories and replace files
+	err := easyfiles.Rename(s.fs, dst, s.path("moved"))
+	if supported(t, "Rename", err) {
+		if err != nil {
+			t.Fatalf("Rename: %v", err)
+		}
+		s.checkMissing(t, dst)
+		if err := s.fs.WriteFile(dst, []byte("other"), 0664); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+		if err := easyfiles.Rename(s.fs, dst, s.path("moved")); err != nil {
+			t.Fatalf("Rename over a file: %v", err)
+		}
+		if data := read(s.path("moved")); data != "other" {
+			t.Errorf("Rename over a file: have %q want %q", data, "other")
+		}
+	}
+
+	err = easyfiles.Chmod(s.fs, src, 0600)
+	if supported(t, "Chmod", err) {
+		if err != nil {
+			t.Fatalf("Chmod: %v", err)
+		}
+		if info, err := s.fs.Stat(src); err != nil || info.Mode().Perm() != 0600 {
+			t.Errorf("Chmod: Stat = %v, %v", info, err)
+		}
+	}
+
+	mtime := time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC)
+	err = easyfiles.Chtimes(s.fs, src, mtime, mtime)
+	if supported(t, "Chtimes", err) {
+		if err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+		if info, err := s.fs.Stat(src); err != nil || !info.ModTime().Equal(mtime) {
+			t.Errorf("Chtimes: Stat = %v, %v", info, err)
+		}
+	}
+
+	err = easyfiles.Truncate(s.fs, src, 2)
+	if supported(t, "Truncate", err) {
+		if err != nil {
+			t.Fatalf("Truncate: %v", err)
+		}
+		if data := read(src); data != "he" {
+			t.Errorf("Truncate: have %q want %q", data, "he")
+		}
+	}
+
+	link := s.path("link")
+	err = easyfiles.Symlink(s.fs, src, link)
+	if supported(t, "Symlink", err) {
+		if err != nil {
+			t.Fatalf("Symlink: %v", err)
+		}
+		if target, err := easyfiles.Readlink(s.fs, link); err != nil || target != src {
+			t.Errorf("Readlink: have %q, %v want %q", target, err, src)
+		}
+		if have, want := read(link), read(src); have != want {
+			t.Errorf("Reading through link: have %q want %q", have, want)
+		}
+	}
+}
+
+func checkStrings(t *testing.T, what string, have, want []string) {
+	t.Helper()
+	if len(have) != len(want) {
+		t.Errorf("%v: have %q want %q", what, have, want)
+		return
+	}
+	for idx := range have {
+		if have[idx] != want[idx] {
+			t.Errorf("%v: have %q want %q", what, have, want)
+			return
+		}
+	}
+}
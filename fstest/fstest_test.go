@@ -0,0 +1,42 @@
+package fstest
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/gurupras/go-easyfiles"
+)
+
+func TestLocalFS(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fstest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	TestFS(t, easyfiles.LocalFS, Options{Dir: dir})
+}
+
+func TestMemFS(t *testing.T) {
+	TestFS(t, easyfiles.NewMemFS(), Options{Dir: "/test"})
+}
+
+func TestChecksumFS(t *testing.T) {
+	TestFS(t, easyfiles.NewChecksumFileSystem(easyfiles.NewMemFS(), 0), Options{Dir: "/test"})
+}
+
+func TestMountFS(t *testing.T) {
+	// The suite runs inside a mount, below another one
+	m := easyfiles.NewMountFS()
+	m.Mount("/", easyfiles.NewMemFS(), "/")
+	m.Mount("/mnt/test", easyfiles.NewMemFS(), "/inner")
+	TestFS(t, m, Options{Dir: "/mnt/test/fstest"})
+}
+
+func TestOverlayFS(t *testing.T) {
+	// The suite's directory comes from the lower layer, and has to be
+	// copied up before anything can be written to it
+	lower := easyfiles.NewMemFS()
+	lower.Makedirs("/test")
+	TestFS(t, easyfiles.NewOverlayFS(lower, easyfiles.NewMemFS()), Options{Dir: "/test"})
+}
@@ -0,0 +1,580 @@
+package easyfiles
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Glob syntax, shared by every filesystem:
+//
+//	*        any sequence of characters other than /
+//	?        any single character other than /
+//	[abc]    a character class; [!abc] or [^abc] negates it, [a-z] is a range
+//	**       as a whole path component, any number of directories (including none)
+//	{a,b}    either a or b; braces may nest and contain any of the above
+//	\x       x, literally
+//
+// A trailing slash is ignored. Patterns are matched against whole,
+// slash-separated paths.
+
+// GlobPattern is a compiled glob pattern
+type GlobPattern struct {
+	// One list of path components per brace alternative, as written and
+	// as matched (lower case if caseInsensitive)
+	literal         [][]string
+	alternatives    [][]string
+	caseInsensitive bool
+}
+
+// CompileGlob compiles pattern. If caseInsensitive is set, letters match
+// regardless of case.
+func CompileGlob(pattern string, caseInsensitive bool) (*GlobPattern, error) {
+	expanded, err := expandBraces(pattern)
+	if err != nil {
+		return nil, err
+	}
+	g := &GlobPattern{caseInsensitive: caseInsensitive}
+	for _, alternative := range expanded {
+		alternative = path.Clean(alternative)
+		g.literal = append(g.literal, splitGlobPath(alternative))
+		if caseInsensitive {
+			alternative = strings.ToLower(alternative)
+		}
+		components := splitGlobPath(alternative)
+		for idx, component := range components {
+			component = convertGlobComponent(component)
+			if _, err := path.Match(component, ""); err != nil {
+				return nil, err
+			}
+			components[idx] = component
+		}
+		g.alternatives = append(g.alternatives, components)
+	}
+	return g, nil
+}
+
+// MatchGlob reports whether name matches pattern
+func MatchGlob(pattern, name string) (bool, error) {
+	g, err := CompileGlob(pattern, false)
+	if err != nil {
+		return false, err
+	}
+	return g.Match(name), nil
+}
+
+// Match reports whether name matches the pattern
+func (g *GlobPattern) Match(name string) bool {
+	if g.caseInsensitive {
+		name = strings.ToLower(name)
+	}
+	parts := splitGlobPath(filepath.ToSlash(name))
+	for _, alternative := range g.alternatives {
+		if matchGlobComponents(alternative, parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesBelow reports whether anything below the directory dir could
+// match the pattern
+func (g *GlobPattern) matchesBelow(dir string) bool {
+	if g.caseInsensitive {
+		dir = strings.ToLower(dir)
+	}
+	parts := splitGlobPath(filepath.ToSlash(dir))
+	for _, alternative := range g.alternatives {
+		if matchGlobPrefix(alternative, parts) {
+			return true
+		}
+	}
+	return false
+}
+
+// root returns the longest directory every match must be in, and whether
+// the pattern is a literal path. When matching regardless of case, a
+// component with letters in it may match directories spelled differently,
+// so it ends the prefix the way a wildcard would.
+func (g *GlobPattern) root() (string, bool) {
+	var prefix []string
+	literal := true
+	for idx, alternative := range g.literal {
+		n := 0
+		for n < len(alternative) && !hasGlobMeta(alternative[n]) && !(g.caseInsensitive && hasCase(alternative[n])) {
+			n++
+		}
+		if n < len(alternative) {
+			literal = false
+		}
+		if idx == 0 {
+			prefix = alternative[:n]
+			continue
+		}
+		if n < len(prefix) {
+			prefix = prefix[:n]
+		}
+		for i := range prefix {
+			if prefix[i] != alternative[i] {
+				prefix = prefix[:i]
+				break
+			}
+		}
+	}
+	if literal && len(g.literal) == 1 {
+		return joinGlobPath(prefix), true
+	}
+	if literal && len(prefix) > 0 {
+		// Alternatives that are each literal paths; the last component
+		// of the shortest is a candidate, not a directory to walk
+		prefix = prefix[:len(prefix)-1]
+	}
+	return joinGlobPath(prefix), false
+}
+
+func joinGlobPath(components []string) string {
+	switch {
+	case len(components) == 0:
+		return "."
+	case len(components) == 1 && components[0] == "":
+		return "/"
+	}
+	return strings.Join(components, "/")
+}
+
+func splitGlobPath(p string) []string {
+	switch p {
+	case ".":
+		return nil
+	case "/":
+		return []string{""}
+	}
+	return strings.Split(strings.TrimSuffix(p, "/"), "/")
+}
+
+func hasCase(component string) bool {
+	return strings.ToLower(component) != strings.ToUpper(component)
+}
+
+func hasGlobMeta(component string) bool {
+	return strings.ContainsAny(component, `*?[\`)
+}
+
+// convertGlobComponent turns [!..] into the [^..] path.Match expects
+func convertGlobComponent(component string) string {
+	if !strings.Contains(component, "[!") {
+		return component
+	}
+	b := []byte(component)
+	for idx := 0; idx < len(b); idx++ {
+		switch b[idx] {
+		case '\\':
+			idx++
+		case '[':
+			if idx+1 < len(b) && b[idx+1] == '!' {
+				b[idx+1] = '^'
+			}
+		}
+	}
+	return string(b)
+}
+
+func matchGlobComponents(pattern, parts []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 0 {
+				return true
+			}
+			for idx := 0; idx <= len(parts); idx++ {
+				if matchGlobComponents(pattern, parts[idx:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(parts) == 0 || !matchGlobComponent(pattern[0], parts[0]) {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(parts) == 0
+}
+
+func matchGlobPrefix(pattern, parts []string) bool {
+	for len(parts) > 0 {
+		if len(pattern) == 0 {
+			return false
+		}
+		if pattern[0] == "**" {
+			return true
+		}
+		if !matchGlobComponent(pattern[0], parts[0]) {
+			return false
+		}
+		pattern, parts = pattern[1:], parts[1:]
+	}
+	return len(pattern) > 0
+}
+
+func matchGlobComponent(pattern, name string) bool {
+	// Only the leading component of an absolute path is empty, and only
+	// an empty pattern component matches it
+	if name == "" || pattern == "" {
+		return name == pattern
+	}
+	m, _ := path.Match(pattern, name)
+	return m
+}
+
+// expandBraces returns every alternative spelled out by the braces in
+// pattern
+func expandBraces(pattern string) ([]string, error) {
+	depth, start := 0, -1
+	for idx := 0; idx < len(pattern); idx++ {
+		switch pattern[idx] {
+		case '\\':
+			idx++
+		case '[':
+			idx = skipGlobClass(pattern, idx)
+		case '{':
+			if depth == 0 {
+				start = idx
+			}
+			depth++
+		case '}':
+			// An unmatched } is literal
+			if depth == 0 {
+				continue
+			}
+			depth--
+			if depth > 0 {
+				continue
+			}
+			ret := make([]string, 0)
+			for _, alternative := range splitBraceAlternatives(pattern[start+1 : idx]) {
+				expanded, err := expandBraces(pattern[:start] + alternative + pattern[idx+1:])
+				if err != nil {
+					return nil, err
+				}
+				ret = append(ret, expanded...)
+			}
+			return ret, nil
+		}
+	}
+	if depth > 0 {
+		return nil, path.ErrBadPattern
+	}
+	return []string{pattern}, nil
+}
+
+// skipGlobClass returns the index of the ] closing the class that starts
+// at idx, or the end of pattern
+func skipGlobClass(pattern string, idx int) int {
+	idx++
+	if idx < len(pattern) && (pattern[idx] == '!' || pattern[idx] == '^') {
+		idx++
+	}
+	if idx < len(pattern) && pattern[idx] == ']' {
+		idx++
+	}
+	for idx < len(pattern) && pattern[idx] != ']' {
+		if pattern[idx] == '\\' {
+			idx++
+		}
+		idx++
+	}
+	return idx
+}
+
+// splitBraceAlternatives splits the inside of a brace on its top level
+// commas
+func splitBraceAlternatives(s string) []string {
+	ret := make([]string, 0)
+	depth, start := 0, 0
+	for idx := 0; idx < len(s); idx++ {
+		switch s[idx] {
+		case '\\':
+			idx++
+		case '[':
+			idx = skipGlobClass(s, idx)
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				ret = append(ret, s[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+	return append(ret, s[start:])
+}
+
+// GlobSet is an ordered list of patterns, some of which may be negated
+// with a leading !. As with .gitignore files, the last pattern matching
+// a name decides whether it is in the set.
+type GlobSet struct {
+	patterns []*GlobPattern
+	negated  []bool
+}
+
+// CompileGlobSet compiles patterns into a GlobSet
+func CompileGlobSet(patterns []string, caseInsensitive bool) (*GlobSet, error) {
+	s := &GlobSet{}
+	for _, pattern := range patterns {
+		negated := strings.HasPrefix(pattern, "!")
+		g, err := CompileGlob(strings.TrimPrefix(pattern, "!"), caseInsensitive)
+		if err != nil {
+			return nil, err
+		}
+		s.patterns = append(s.patterns, g)
+		s.negated = append(s.negated, negated)
+	}
+	return s, nil
+}
+
+// Match reports whether name is in the set
+func (s *GlobSet) Match(name string) bool {
+	matched := false
+	for idx, g := range s.patterns {
+		if matched == s.negated[idx] && g.Match(name) {
+			matched = !s.negated[idx]
+		}
+	}
+	return matched
+}
+
+// IgnoreRules are the rules of a .gitignore-style file. Patterns without
+// a slash match at any depth below the directory the rules apply to;
+// patterns with one are relative to it. A trailing slash restricts a
+// pattern to directories, a leading ! re-includes what an earlier
+// pattern excluded, and anything below an ignored directory is ignored
+// too.
+type IgnoreRules struct {
+	base  string
+	rules []ignoreRule
+}
+
+type ignoreRule struct {
+	pattern *GlobPattern
+	negated bool
+	dirOnly bool
+}
+
+// ParseIgnoreRules parses the contents of an ignore file whose rules
+// apply below the directory base
+func ParseIgnoreRules(base string, data []byte) (*IgnoreRules, error) {
+	r := &IgnoreRules{base: base}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if !strings.HasSuffix(line, `\ `) {
+			line = strings.TrimRight(line, " ")
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negated = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\#`) || strings.HasPrefix(line, `\!`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			rule.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if !strings.Contains(line, "/") {
+			line = "**/" + line
+		}
+		g, err := CompileGlob(strings.TrimPrefix(line, "/"), false)
+		if err != nil {
+			return nil, err
+		}
+		rule.pattern = g
+		r.rules = append(r.rules, rule)
+	}
+	return r, nil
+}
+
+// LoadIgnoreFile reads the ignore file at name. Its rules apply below the
+// directory containing it.
+func LoadIgnoreFile(fs FileSystemInterface, name string) (*IgnoreRules, error) {
+	data, err := fs.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIgnoreRules(filepath.Dir(name), data)
+}
+
+// decide returns whether the rules ignore name, and whether any rule
+// applied to it at all
+func (r *IgnoreRules) decide(rel string, isDir bool) (ignored bool, matched bool) {
+	for _, rule := range r.rules {
+		if rule.dirOnly && !isDir {
+			continue
+		}
+		if rule.pattern.Match(rel) {
+			ignored, matched = !rule.negated, true
+		}
+	}
+	return
+}
+
+func (r *IgnoreRules) rel(name string) (string, bool) {
+	rel, err := filepath.Rel(r.base, name)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// Ignored reports whether the rules ignore name, either directly or
+// because one of the directories it is in is ignored
+func (r *IgnoreRules) Ignored(name string, isDir bool) bool {
+	rel, ok := r.rel(name)
+	if !ok {
+		return false
+	}
+	parts := strings.Split(rel, "/")
+	for idx := 1; idx < len(parts); idx++ {
+		if ignored, _ := r.decide(strings.Join(parts[:idx], "/"), true); ignored {
+			return true
+		}
+	}
+	ignored, _ := r.decide(rel, isDir)
+	return ignored
+}
+
+// GlobOptions controls GlobWithOptions
+type GlobOptions struct {
+	// CaseInsensitive matches letters regardless of case, in directory
+	// names too. The walk then has to start above the first directory
+	// with letters in its name.
+	CaseInsensitive bool
+	// Exclude removes paths matching any of these patterns from the
+	// results. They are matched against the same paths as the pattern, so
+	// use **/name to exclude a name anywhere.
+	Exclude []string
+	// Ignore rules leave out what they ignore, including everything below
+	// ignored directories
+	Ignore []*IgnoreRules
+	// IgnoreFileName, if set, is the name of ignore files (.gitignore, ..)
+	// to honour in every directory walked. Their rules apply below the
+	// directory containing them and override those of enclosing
+	// directories. Ignore files above the directory the walk starts in
+	// aren't read.
+	IgnoreFileName string
+}
+
+// Glob returns the paths on fs matching pattern, sorted. It behaves the
+// same on every filesystem. Symbolic links aren't followed.
+func Glob(fs FileSystemInterface, pattern string) ([]string, error) {
+	return GlobWithOptions(fs, pattern, nil)
+}
+
+type scopedIgnoreRules struct {
+	dir   string
+	rules *IgnoreRules
+}
+
+// GlobWithOptions is Glob with exclusions and ignore files. opts may be
+// nil.
+func GlobWithOptions(fs FileSystemInterface, pattern string, opts *GlobOptions) ([]string, error) {
+	if opts == nil {
+		opts = &GlobOptions{}
+	}
+	g, err := CompileGlob(pattern, opts.CaseInsensitive)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := CompileGlobSet(opts.Exclude, opts.CaseInsensitive)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make([]string, 0)
+	root, literal := g.root()
+	if literal {
+		info, err := statPath(fs, root)
+		if err == nil && !exclude.Match(root) && !ignoredBy(opts.Ignore, root, info.IsDir()) {
+			matches = append(matches, root)
+		}
+		return matches, nil
+	}
+
+	// Rules from ignore files found during the walk, outermost first
+	scoped := make([]scopedIgnoreRules, 0)
+	w := NewWalker(fs, root, &WalkOptions{Sort: true, OnError: func(path string, err error) error {
+		// Unreadable and missing directories just don't match anything
+		return nil
+	}})
+	defer w.Close()
+	for w.Next() {
+		entry := w.Entry()
+		isDir := entry.Info.IsDir()
+		for len(scoped) > 0 && !isBelow(entry.Path, scoped[len(scoped)-1].dir) {
+			scoped = scoped[:len(scoped)-1]
+		}
+		if entry.Depth > 0 && (ignoredBy(opts.Ignore, entry.Path, isDir) || ignoredByScoped(scoped, entry.Path, isDir)) {
+			w.SkipDir()
+			continue
+		}
+		if (entry.Depth > 0 || root != ".") && g.Match(entry.Path) && !exclude.Match(entry.Path) {
+			matches = append(matches, entry.Path)
+		}
+		if !isDir {
+			continue
+		}
+		if !g.matchesBelow(entry.Path) {
+			w.SkipDir()
+			continue
+		}
+		if opts.IgnoreFileName != "" {
+			rules, err := LoadIgnoreFile(fs, filepath.Join(entry.Path, opts.IgnoreFileName))
+			if err == nil {
+				scoped = append(scoped, scopedIgnoreRules{entry.Path, rules})
+			} else if !os.IsNotExist(err) {
+				return nil, err
+			}
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+func isBelow(name, dir string) bool {
+	rel, err := filepath.Rel(dir, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func ignoredBy(rules []*IgnoreRules, name string, isDir bool) bool {
+	for _, r := range rules {
+		if r.Ignored(name, isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// ignoredByScoped applies nested ignore files; the innermost file with a
+// rule for name decides. Ignored directories are pruned by the walk, so
+// their contents never get this far.
+func ignoredByScoped(scoped []scopedIgnoreRules, name string, isDir bool) bool {
+	for idx := len(scoped) - 1; idx >= 0; idx-- {
+		rel, ok := scoped[idx].rules.rel(name)
+		if !ok {
+			continue
+		}
+		if ignored, matched := scoped[idx].rules.decide(rel, isDir); matched {
+			return ignored
+		}
+	}
+	return false
+}
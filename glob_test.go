@@ -0,0 +1,207 @@
+package easyfiles
+
+import (
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchGlob(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	tests := []struct {
+		pattern string
+		name    string
+		match   bool
+	}{
+		{"*.txt", "a.txt", true},
+		{"*.txt", "dir/a.txt", false},
+		{"**/*.txt", "a.txt", true},
+		{"**/*.txt", "dir/sub/a.txt", true},
+		{"dir/**", "dir", true},
+		{"dir/**", "dir/sub/a", true},
+		{"a/**/b", "a/b", true},
+		{"a/**/b", "a/x/y/b", true},
+		{"a/**/b", "a/x/y/c", false},
+		{"?.txt", "ab.txt", false},
+		{"[ab].txt", "b.txt", true},
+		{"[!ab].txt", "b.txt", false},
+		{"[^ab].txt", "c.txt", true},
+		{"[a-c]x", "cx", true},
+		{"{a,b}.txt", "b.txt", true},
+		{"{a,b}.txt", "c.txt", false},
+		{"x.{txt,{gz,bz2}}", "x.bz2", true},
+		{"{src,lib/**}/*.go", "lib/x/y.go", true},
+		{"{[ab],c}", "b", true},
+		{`\*.txt`, "*.txt", true},
+		{`\*.txt`, "a.txt", false},
+		{`\{a,b}`, "{a,b}", true},
+		{"a}", "a}", true},
+		{"./a/*", "a/x", true},
+		{"dir/", "dir", true},
+		{"/abs/*", "/abs/x", true},
+		{"*/x", "/x", false},
+		{"**/x", "/a/x", true},
+	}
+	for _, test := range tests {
+		m, err := MatchGlob(test.pattern, test.name)
+		require.Nil(err, test.pattern)
+		require.Equal(test.match, m, "%v %v", test.pattern, test.name)
+	}
+
+	for _, pattern := range []string{"[", "{a,b", "x/[a-"} {
+		_, err := MatchGlob(pattern, "a")
+		require.Equal(path.ErrBadPattern, err, pattern)
+	}
+
+	g, err := CompileGlob("Data/*.{CSV,txt}", true)
+	require.Nil(err)
+	require.True(g.Match("data/x.csv"))
+	require.True(g.Match("DATA/X.TXT"))
+	require.False(g.Match("data/x.gz"))
+}
+
+func TestGlobSet(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	s, err := CompileGlobSet([]string{"*.txt", "!a*", "ab.txt"}, false)
+	require.Nil(err)
+	require.True(s.Match("x.txt"))
+	require.False(s.Match("a.txt"))
+	require.True(s.Match("ab.txt"))
+	require.False(s.Match("x.gz"))
+
+	// Only negated patterns match nothing
+	s, err = CompileGlobSet([]string{"!*.txt"}, false)
+	require.Nil(err)
+	require.False(s.Match("x.gz"))
+
+	_, err = CompileGlobSet([]string{"ok", "!["}, false)
+	require.NotNil(err)
+}
+
+func TestIgnoreRules(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	r, err := ParseIgnoreRules("/repo", []byte(`# build output
+*.o
+build/
+/top.txt
+docs/*.md
+!keep.o
+\#hash
+trailing
+`))
+	require.Nil(err)
+
+	tests := []struct {
+		name    string
+		isDir   bool
+		ignored bool
+	}{
+		{"/repo/x.o", false, true},
+		{"/repo/src/deep/x.o", false, true},
+		{"/repo/src/keep.o", false, false},
+		{"/repo/build", true, true},
+		{"/repo/build", false, false},
+		{"/repo/src/build/out", false, true},
+		{"/repo/top.txt", false, true},
+		{"/repo/src/top.txt", false, false},
+		{"/repo/docs/a.md", false, true},
+		{"/repo/docs/sub/a.md", false, false},
+		{"/repo/#hash", false, true},
+		{"/repo/trailing", false, true},
+		{"/other/x.o", false, false},
+		{"/repo", true, false},
+	}
+	for _, test := range tests {
+		require.Equal(test.ignored, r.Ignored(test.name, test.isDir), test.name)
+	}
+}
+
+func makeGlobTree(require *require.Assertions, fs FileSystemInterface, root string) {
+	for _, name := range []string{
+		"a.txt", "B.TXT", "c.gz", "src/x.go", "src/x_test.go", "src/sub/y.go",
+		"lib/z.go", "build/out.o", "vendor/v.go", ".hidden/h.txt",
+	} {
+		p := filepath.Join(root, name)
+		require.Nil(fs.Makedirs(filepath.Dir(p)))
+		require.Nil(fs.WriteFile(p, nil, 0664))
+	}
+}
+
+func TestGlob(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir := t.TempDir()
+	makeGlobTree(require, LocalFS, dir)
+	mem := NewMemFS()
+	makeGlobTree(require, mem, "/root")
+
+	for _, test := range []struct {
+		fs   FileSystemInterface
+		root string
+	}{{LocalFS, dir}, {mem, "/root"}} {
+		glob := func(pattern string, opts *GlobOptions) []string {
+			matches, err := GlobWithOptions(test.fs, filepath.Join(test.root, pattern), opts)
+			require.Nil(err, pattern)
+			rel := make([]string, len(matches))
+			for idx, match := range matches {
+				rel[idx], _ = filepath.Rel(test.root, match)
+			}
+			return rel
+		}
+
+		require.Equal([]string{"a.txt"}, glob("*.txt", nil))
+		require.Equal([]string{"B.TXT", "a.txt"}, glob("*.txt", &GlobOptions{CaseInsensitive: true}))
+		require.Equal([]string{"src/sub/y.go", "src/x.go"}, glob("SRC/{SUB/Y,X}.GO", &GlobOptions{CaseInsensitive: true}))
+		require.Equal([]string{"c.gz"}, glob("C.GZ", &GlobOptions{CaseInsensitive: true}))
+		require.Equal([]string{".hidden/h.txt", "a.txt"}, glob("**/*.txt", nil))
+		require.Equal([]string{"lib/z.go", "src/x.go", "src/x_test.go"}, glob("{src,lib}/*.go", nil))
+		require.Equal([]string{"lib/z.go", "src/sub/y.go", "src/x.go"}, glob("**/*.go", &GlobOptions{Exclude: []string{"**/*_test.go", "**/vendor/**"}}))
+		require.Equal([]string{"B.TXT"}, glob("[!.a-z]*", nil))
+		require.Equal([]string{"c.gz"}, glob("c.gz", nil))
+		require.Equal([]string{}, glob("missing.gz", nil))
+		require.Equal([]string{}, glob("missing/*", nil))
+
+		_, err := Glob(test.fs, filepath.Join(test.root, "{x"))
+		require.Equal(path.ErrBadPattern, err)
+
+		// Ignore files in nested directories
+		require.Nil(test.fs.WriteFile(filepath.Join(test.root, ".ignore"), []byte("build/\nvendor\n*_test.go\n.*\n"), 0664))
+		require.Nil(test.fs.WriteFile(filepath.Join(test.root, "src", ".ignore"), []byte("!*_test.go\nsub/\n"), 0664))
+		opts := &GlobOptions{IgnoreFileName: ".ignore"}
+		require.Equal([]string{"lib/z.go", "src/x.go", "src/x_test.go"}, glob("**/*.go", opts))
+		require.Equal([]string{"a.txt"}, glob("**/*.txt", opts))
+
+		rules, err := LoadIgnoreFile(test.fs, filepath.Join(test.root, ".ignore"))
+		require.Nil(err)
+		require.Equal([]string{"B.TXT", "c.gz", "lib", "src"}, glob("*", &GlobOptions{Ignore: []*IgnoreRules{rules}, Exclude: []string{"**/a.*"}}))
+	}
+}
+
+func TestGlobBackends(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Every backend's Glob is the same engine, relative patterns included
+	mem := NewMemFS()
+	makeGlobTree(require, mem, "/")
+	iofs := FromIOFS(ToIOFS(mem, "/"))
+	for _, pattern := range []string{"*", "**/*.go", "{src,lib}/**", ".*/*"} {
+		expected, err := Glob(mem, pattern)
+		require.Nil(err)
+		require.NotEmpty(expected)
+		for _, fs := range []FileSystemInterface{mem, iofs} {
+			matches, err := fs.Glob(pattern)
+			require.Nil(err)
+			require.Equal(expected, matches, pattern)
+		}
+	}
+}
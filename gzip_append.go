@@ -0,0 +1,62 @@
+package easyfiles
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// GzipAppendError is returned by OpenGzipAppend when the existing file
+// isn't a complete gzip stream
+type GzipAppendError struct {
+	Path string
+	Err  error
+}
+
+func (e *GzipAppendError) Error() string {
+	return fmt.Sprintf("Cannot append to %v: %v", e.Path, e.Err)
+}
+
+// ValidateGzip reads r to the end as a gzip stream of one or more
+// members, checking every member's CRC-32 and size trailer
+func ValidateGzip(r io.Reader) error {
+	reader, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.Copy(ioutil.Discard, reader)
+	return err
+}
+
+// OpenGzipAppend opens path for appending a new gzip member to it,
+// creating it if it doesn't exist. An existing file is validated first,
+// so data is never appended to a truncated or corrupt stream. Writers
+// returned by the File start a new member, and since gzip readers treat
+// concatenated members as a single stream, RawReader returns the old
+// and new data as one continuous stream.
+//
+// Validating reads through the whole existing file.
+func OpenGzipAppend(fs FileSystemInterface, path string) (*File, error) {
+	info, err := fs.Stat(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil && info != nil && info.Size() > 0 {
+		if info.IsDir() {
+			return nil, &GzipAppendError{path, fmt.Errorf("is a directory")}
+		}
+		f, err := fs.Open(path, os.O_RDONLY, GZ_FALSE)
+		if err != nil {
+			return nil, err
+		}
+		err = ValidateGzip(f.File)
+		f.Close()
+		if err != nil {
+			return nil, &GzipAppendError{path, err}
+		}
+	}
+	return fs.Open(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, GZ_TRUE)
+}
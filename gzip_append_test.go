@@ -0,0 +1,66 @@
+package easyfiles
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func appendGzip(require *require.Assertions, path string, data string) {
+	f, err := OpenGzipAppend(LocalFS, path)
+	require.Nil(err)
+	w, err := f.Writer(0)
+	require.Nil(err)
+	_, err = w.Write([]byte(data))
+	require.Nil(err)
+	require.Nil(f.Close())
+}
+
+func TestOpenGzipAppend(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "gzip-append")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "out.gz")
+	appendGzip(require, fname, "Hello ")
+	appendGzip(require, fname, "World")
+	appendGzip(require, fname, "\n")
+
+	f, err := Open(fname, os.O_RDONLY, GZ_UNKNOWN)
+	require.Nil(err)
+	defer f.Close()
+	success, err := CheckFileContentsMatch(f, []byte("Hello World\n"), true, 0)
+	require.Nil(err)
+	require.True(success)
+}
+
+func TestOpenGzipAppendCorrupt(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "gzip-append")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "out.gz")
+	appendGzip(require, fname, "Hello World\n")
+
+	// Chop off the trailer
+	b, err := ioutil.ReadFile(fname)
+	require.Nil(err)
+	require.Nil(ioutil.WriteFile(fname, b[:len(b)-4], 0664))
+	_, err = OpenGzipAppend(LocalFS, fname)
+	require.NotNil(err)
+	_, ok := err.(*GzipAppendError)
+	require.True(ok)
+
+	// Not gzip at all
+	_, err = OpenGzipAppend(LocalFS, "test/open-test.fake.gz")
+	require.NotNil(err)
+}
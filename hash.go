@@ -0,0 +1,296 @@
+package easyfiles
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type HashAlgorithm int
+
+const (
+	HASH_MD5 HashAlgorithm = iota
+	HASH_SHA1
+	HASH_SHA256
+	HASH_CRC32C
+)
+
+const (
+	CHECKSUM_SIDECAR_SUFFIX = ".sha256"
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+func (a HashAlgorithm) String() string {
+	switch a {
+	case HASH_MD5:
+		return "MD5"
+	case HASH_SHA1:
+		return "SHA1"
+	case HASH_SHA256:
+		return "SHA256"
+	case HASH_CRC32C:
+		return "CRC32C"
+	}
+	panic("Shouldn't be here")
+}
+
+// New returns a fresh hash.Hash for the algorithm
+func (a HashAlgorithm) New() hash.Hash {
+	switch a {
+	case HASH_MD5:
+		return md5.New()
+	case HASH_SHA1:
+		return sha1.New()
+	case HASH_SHA256:
+		return sha256.New()
+	case HASH_CRC32C:
+		return crc32.New(crc32cTable)
+	}
+	panic("Shouldn't be here")
+}
+
+// Hasher is an io.Writer that feeds everything written to it into one
+// hash per requested algorithm.
+type Hasher struct {
+	algs   []HashAlgorithm
+	hashes []hash.Hash
+	size   int64
+}
+
+// NewHasher returns a Hasher computing the given algorithms. If no
+// algorithm is given, SHA-256 is used.
+func NewHasher(algs ...HashAlgorithm) *Hasher {
+	if len(algs) == 0 {
+		algs = []HashAlgorithm{HASH_SHA256}
+	}
+	h := &Hasher{algs: algs, hashes: make([]hash.Hash, len(algs))}
+	for idx, alg := range algs {
+		h.hashes[idx] = alg.New()
+	}
+	return h
+}
+
+func (h *Hasher) Write(p []byte) (int, error) {
+	for _, v := range h.hashes {
+		v.Write(p)
+	}
+	h.size += int64(len(p))
+	return len(p), nil
+}
+
+// Size returns the number of bytes hashed so far
+func (h *Hasher) Size() int64 {
+	return h.size
+}
+
+// Sum returns the digest for alg, or nil if the Hasher isn't computing alg
+func (h *Hasher) Sum(alg HashAlgorithm) []byte {
+	for idx, v := range h.algs {
+		if v == alg {
+			return h.hashes[idx].Sum(nil)
+		}
+	}
+	return nil
+}
+
+// HexSum returns the digest for alg as a lowercase hex string
+func (h *Hasher) HexSum(alg HashAlgorithm) string {
+	return hex.EncodeToString(h.Sum(alg))
+}
+
+// HashingReader reads the uncompressed contents of a File while hashing
+// both the bytes as they are stored (Raw) and the bytes handed back to
+// the caller (Data). For files that aren't compressed the two are equal.
+// Raw only covers the whole file once the reader has returned io.EOF.
+type HashingReader struct {
+	io.Reader
+	Raw  *Hasher
+	Data *Hasher
+}
+
+// HashingReader is the hashing counterpart of RawReader
+func (f *File) HashingReader(algs ...HashAlgorithm) (*HashingReader, error) {
+	raw := NewHasher(algs...)
+	data := NewHasher(algs...)
+	tee := io.TeeReader(f.File, raw)
+
+	var reader io.Reader
+	switch f.Gz {
+	case GZ_TRUE:
+		gzReader, err := gzip.NewReader(tee)
+		if err != nil {
+			return nil, err
+		}
+		reader = gzReader
+	case GZ_FALSE:
+		reader = bufio.NewReader(tee)
+	default:
+		panic("Should not have occured..mode should have been fixed on open")
+	}
+	return &HashingReader{io.TeeReader(reader, data), raw, data}, nil
+}
+
+// HashingWriter is a Writer that hashes the bytes written to it (Data)
+// as well as the bytes that end up in the file (Raw). Raw is only
+// complete once the writer has been closed.
+type HashingWriter struct {
+	*Writer
+	Raw  *Hasher
+	Data *Hasher
+}
+
+// HashingWriter is the hashing counterpart of Writer
+func (f *File) HashingWriter(bufsize int, algs ...HashAlgorithm) (*HashingWriter, error) {
+	raw := NewHasher(algs...)
+	data := NewHasher(algs...)
+	layers := []LayerFunc{HashLayer(data)}
+	layers = append(layers, f.compressionLayers()...)
+	layers = append(layers, HashLayer(raw))
+	writer, err := f.LayeredWriter(bufsize, layers...)
+	if err != nil {
+		return nil, err
+	}
+	return &HashingWriter{writer, raw, data}, nil
+}
+
+// ChecksumMismatchError is returned when a file does not match the
+// checksum recorded for it
+type ChecksumMismatchError struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumMismatchError) Error() string {
+	return fmt.Sprintf("Checksum mismatch for %v: expected %v, got %v", e.Path, e.Expected, e.Actual)
+}
+
+// ChecksumEntry is a single line of a sha256sum-style checksum file
+type ChecksumEntry struct {
+	Sum    string
+	Name   string
+	Binary bool
+}
+
+// ParseChecksums parses the output of sha256sum (or md5sum, sha1sum, ..)
+func ParseChecksums(data []byte) ([]ChecksumEntry, error) {
+	entries := make([]ChecksumEntry, 0)
+	for idx, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		// Escaped names are prefixed with a backslash
+		line = strings.TrimPrefix(line, "\\")
+		sep := strings.Index(line, " ")
+		if sep <= 0 || sep+2 > len(line) {
+			return nil, fmt.Errorf("Malformed checksum line %d: %q", idx+1, line)
+		}
+		sum := line[:sep]
+		if _, err := hex.DecodeString(sum); err != nil {
+			return nil, fmt.Errorf("Malformed checksum line %d: %v", idx+1, err)
+		}
+		entry := ChecksumEntry{Sum: strings.ToLower(sum)}
+		switch line[sep+1] {
+		case '*':
+			entry.Binary = true
+		case ' ':
+		default:
+			return nil, fmt.Errorf("Malformed checksum line %d: %q", idx+1, line)
+		}
+		entry.Name = line[sep+2:]
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// FormatChecksums is the inverse of ParseChecksums
+func FormatChecksums(entries []ChecksumEntry) []byte {
+	buf := bytes.NewBuffer(nil)
+	for _, entry := range entries {
+		marker := " "
+		if entry.Binary {
+			marker = "*"
+		}
+		fmt.Fprintf(buf, "%v %v%v\n", entry.Sum, marker, entry.Name)
+	}
+	return buf.Bytes()
+}
+
+// FileChecksum computes the digest of the bytes stored at path, without
+// decompressing them
+func FileChecksum(fs FileSystemInterface, path string, alg HashAlgorithm) (string, error) {
+	f, err := fs.Open(path, os.O_RDONLY, GZ_FALSE)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := NewHasher(alg)
+	if _, err = io.Copy(h, f.File); err != nil {
+		return "", err
+	}
+	return h.HexSum(alg), nil
+}
+
+// ChecksumSidecarPath returns the path of the sidecar file for path
+func ChecksumSidecarPath(path string) string {
+	return path + CHECKSUM_SIDECAR_SUFFIX
+}
+
+// WriteChecksumSidecar records sum as the SHA-256 of path in a sidecar
+// file next to it. The sidecar can be checked with `sha256sum -c` from
+// within the directory containing path.
+func WriteChecksumSidecar(fs FileSystemInterface, path string, sum string) error {
+	entry := ChecksumEntry{Sum: strings.ToLower(sum), Name: filepath.Base(path)}
+	return fs.WriteFile(ChecksumSidecarPath(path), FormatChecksums([]ChecksumEntry{entry}), 0664)
+}
+
+// CreateChecksumSidecar computes the SHA-256 of path and writes its sidecar
+func CreateChecksumSidecar(fs FileSystemInterface, path string) (string, error) {
+	sum, err := FileChecksum(fs, path, HASH_SHA256)
+	if err != nil {
+		return "", err
+	}
+	return sum, WriteChecksumSidecar(fs, path, sum)
+}
+
+// VerifyChecksumSidecar checks path against its sidecar file. A mismatch
+// is reported as a *ChecksumMismatchError.
+func VerifyChecksumSidecar(fs FileSystemInterface, path string) error {
+	data, err := fs.ReadFile(ChecksumSidecarPath(path))
+	if err != nil {
+		return err
+	}
+	entries, err := ParseChecksums(data)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(path)
+	for _, entry := range entries {
+		if entry.Name != name && entry.Name != path {
+			continue
+		}
+		sum, err := FileChecksum(fs, path, HASH_SHA256)
+		if err != nil {
+			return err
+		}
+		if sum != entry.Sum {
+			return &ChecksumMismatchError{path, entry.Sum, sum}
+		}
+		return nil
+	}
+	return fmt.Errorf("No checksum for %v in %v", name, ChecksumSidecarPath(path))
+}
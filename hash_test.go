@@ -0,0 +1,120 @@
+package easyfiles
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testHashingRoundTrip(t *testing.T, fileType FileType) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "hash")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	data := RandomData(256*1024 + 17)
+	fname := filepath.Join(dir, fmt.Sprintf("hash-%v", fileType))
+
+	f, err := Open(fname, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileType)
+	require.Nil(err)
+	w, err := f.HashingWriter(0, HASH_MD5, HASH_SHA256, HASH_CRC32C)
+	require.Nil(err)
+	n, err := w.Write(data)
+	require.Nil(err)
+	require.Equal(len(data), n)
+	require.Nil(w.Flush())
+	require.Nil(w.Close())
+	require.Nil(f.Close())
+
+	dataSum := sha256.Sum256(data)
+	require.Equal(hex.EncodeToString(dataSum[:]), w.Data.HexSum(HASH_SHA256))
+	md5Sum := md5.Sum(data)
+	require.Equal(md5Sum[:], w.Data.Sum(HASH_MD5))
+	crc := crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))
+	require.Equal([]byte{byte(crc >> 24), byte(crc >> 16), byte(crc >> 8), byte(crc)}, w.Data.Sum(HASH_CRC32C))
+	require.Nil(w.Data.Sum(HASH_SHA1))
+
+	stored, err := ioutil.ReadFile(fname)
+	require.Nil(err)
+	rawSum := sha256.Sum256(stored)
+	require.Equal(hex.EncodeToString(rawSum[:]), w.Raw.HexSum(HASH_SHA256))
+	require.Equal(int64(len(stored)), w.Raw.Size())
+
+	f, err = Open(fname, os.O_RDONLY, fileType)
+	require.Nil(err)
+	defer f.Close()
+	r, err := f.HashingReader(HASH_SHA256)
+	require.Nil(err)
+	got, err := ioutil.ReadAll(r)
+	require.Nil(err)
+	require.Equal(data, got)
+	require.Equal(w.Data.HexSum(HASH_SHA256), r.Data.HexSum(HASH_SHA256))
+	require.Equal(w.Raw.HexSum(HASH_SHA256), r.Raw.HexSum(HASH_SHA256))
+}
+
+func TestHashingGz(t *testing.T) {
+	t.Parallel()
+	testHashingRoundTrip(t, GZ_TRUE)
+}
+
+func TestHashingGzFalse(t *testing.T) {
+	t.Parallel()
+	testHashingRoundTrip(t, GZ_FALSE)
+}
+
+func TestParseChecksums(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	data := []byte("d41d8cd98f00b204e9800998ecf8427e  empty.txt\n0cc175b9c0f1b6a831c399e269772661 *a.bin\n")
+	entries, err := ParseChecksums(data)
+	require.Nil(err)
+	require.Equal([]ChecksumEntry{
+		{"d41d8cd98f00b204e9800998ecf8427e", "empty.txt", false},
+		{"0cc175b9c0f1b6a831c399e269772661", "a.bin", true},
+	}, entries)
+	require.Equal(data, FormatChecksums(entries))
+
+	_, err = ParseChecksums([]byte("not a checksum line\n"))
+	require.NotNil(err)
+}
+
+func TestChecksumSidecar(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "sidecar")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	fname := filepath.Join(dir, "data.txt")
+	require.Nil(LocalFS.WriteFile(fname, []byte("Hello World\n"), 0664))
+
+	sum, err := CreateChecksumSidecar(LocalFS, fname)
+	require.Nil(err)
+	sidecar, err := ioutil.ReadFile(ChecksumSidecarPath(fname))
+	require.Nil(err)
+	require.Equal(fmt.Sprintf("%v  data.txt\n", sum), string(sidecar))
+	require.Nil(VerifyChecksumSidecar(LocalFS, fname))
+
+	f, err := os.OpenFile(fname, os.O_APPEND|os.O_WRONLY, 0664)
+	require.Nil(err)
+	io.WriteString(f, "corrupt")
+	f.Close()
+
+	err = VerifyChecksumSidecar(LocalFS, fname)
+	require.NotNil(err)
+	mismatch, ok := err.(*ChecksumMismatchError)
+	require.True(ok)
+	require.Equal(sum, mismatch.Expected)
+}
@@ -0,0 +1,383 @@
+package easyfiles
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// ErrReadOnly is returned by filesystems that can't be written to
+var ErrReadOnly = errors.New("Filesystem is read-only")
+
+// IOFS exposes a FileSystemInterface as an io/fs filesystem rooted at a
+// directory, so it can be used with http.FileServer, template.ParseFS and
+// friends. Files are served as stored; nothing is decompressed.
+type IOFS struct {
+	fsys FileSystemInterface
+	root string
+}
+
+var (
+	_ fs.ReadDirFS  = (*IOFS)(nil)
+	_ fs.ReadFileFS = (*IOFS)(nil)
+	_ fs.StatFS     = (*IOFS)(nil)
+	_ fs.GlobFS     = (*IOFS)(nil)
+)
+
+// ToIOFS returns an io/fs view of fsys in which the name "." refers to root
+func ToIOFS(fsys FileSystemInterface, root string) *IOFS {
+	return &IOFS{fsys, root}
+}
+
+func (i *IOFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return path.Join(i.root, name), nil
+}
+
+// ioPathError rewraps err so that it refers to the io/fs name rather than
+// the path on the underlying filesystem
+func ioPathError(op, name string, err error) error {
+	var pathErr *os.PathError
+	if errors.As(err, &pathErr) {
+		err = pathErr.Err
+	}
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+func (i *IOFS) Stat(name string) (fs.FileInfo, error) {
+	p, err := i.path("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	info, err := i.fsys.Stat(p)
+	if err != nil {
+		return nil, ioPathError("stat", name, err)
+	}
+	if info == nil {
+		// Some filesystems report missing files this way
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+	if name == "." {
+		info = &renamedFileInfo{info, "."}
+	}
+	return info, nil
+}
+
+func (i *IOFS) Open(name string) (fs.File, error) {
+	info, err := i.Stat(name)
+	if err != nil {
+		return nil, ioPathError("open", name, err)
+	}
+	if info.IsDir() {
+		return &ioDir{i, name, info, nil, false}, nil
+	}
+	p, _ := i.path("open", name)
+	f, err := i.fsys.Open(p, os.O_RDONLY, GZ_FALSE)
+	if err != nil {
+		return nil, ioPathError("open", name, err)
+	}
+	return &ioFile{f, info}, nil
+}
+
+func (i *IOFS) ReadFile(name string) ([]byte, error) {
+	p, err := i.path("readfile", name)
+	if err != nil {
+		return nil, err
+	}
+	b, err := i.fsys.ReadFile(p)
+	if err != nil {
+		return nil, ioPathError("readfile", name, err)
+	}
+	return b, nil
+}
+
+func (i *IOFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	p, err := i.path("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	infos, err := i.fsys.ReadDir(p)
+	if err != nil {
+		return nil, ioPathError("readdir", name, err)
+	}
+	entries := make([]fs.DirEntry, len(infos))
+	for idx, info := range infos {
+		entries[idx] = fs.FileInfoToDirEntry(info)
+	}
+	sort.Slice(entries, func(a, b int) bool { return entries[a].Name() < entries[b].Name() })
+	return entries, nil
+}
+
+// Glob follows path.Match, so unlike FileSystemInterface.Glob, ** is not
+// special
+func (i *IOFS) Glob(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+	matches, err := i.fsys.Glob(path.Join(i.root, pattern))
+	if err != nil {
+		return nil, err
+	}
+	// Matches under a relative root of "." come back without any prefix
+	prefix := ""
+	switch root := path.Clean(i.root); root {
+	case ".":
+	case "/":
+		prefix = "/"
+	default:
+		prefix = root + "/"
+	}
+	ret := make([]string, 0, len(matches))
+	for _, match := range matches {
+		if !strings.HasPrefix(match, prefix) {
+			continue
+		}
+		name := match[len(prefix):]
+		if ok, _ := path.Match(pattern, name); ok {
+			ret = append(ret, name)
+		}
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+type renamedFileInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (r *renamedFileInfo) Name() string {
+	return r.name
+}
+
+type ioFile struct {
+	file *File
+	info fs.FileInfo
+}
+
+func (f *ioFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *ioFile) Read(p []byte) (int, error) {
+	return f.file.File.Read(p)
+}
+
+func (f *ioFile) Seek(offset int64, whence int) (int64, error) {
+	return f.file.Seek(offset, whence)
+}
+
+func (f *ioFile) Close() error {
+	return f.file.Close()
+}
+
+// ioDir is an open directory. Entries are read all at once on the first
+// call to ReadDir.
+type ioDir struct {
+	fsys    *IOFS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	read    bool
+}
+
+func (d *ioDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+func (d *ioDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *ioDir) Close() error {
+	return nil
+}
+
+func (d *ioDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.read {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+		d.read = true
+	}
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
+
+// IOFSFileSystem exposes an io/fs filesystem (embed.FS, fstest.MapFS,
+// os.DirFS, ..) as a read-only FileSystemInterface. Compressed files are
+// detected on Open like on any other filesystem. Names may be given with
+// or without a leading slash.
+type IOFSFileSystem struct {
+	fsys fs.FS
+}
+
+// FromIOFS wraps fsys as a read-only FileSystemInterface
+func FromIOFS(fsys fs.FS) *IOFSFileSystem {
+	return &IOFSFileSystem{fsys}
+}
+
+// name converts a FileSystemInterface path into an io/fs name
+func (i *IOFSFileSystem) name(p string) string {
+	if p == "" {
+		// Keep the empty name invalid rather than turning it into "."
+		return ""
+	}
+	name := strings.TrimPrefix(path.Clean("/"+p), "/")
+	if name == "" {
+		return "."
+	}
+	return name
+}
+
+func readOnlyError(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: ErrReadOnly}
+}
+
+func (i *IOFSFileSystem) Open(name string, mode int, gz FileType) (*File, error) {
+	if mode&(os.O_WRONLY|os.O_RDWR|os.O_APPEND|os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, readOnlyError("open", name)
+	}
+	file, err := i.fsys.Open(i.name(name))
+	if err != nil {
+		return nil, err
+	}
+	iofsFile, err := newIOFSFile(name, file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	f := &File{Path: name, File: iofsFile, Mode: mode, Gz: gz}
+	if gz == GZ_UNKNOWN {
+		f.FixMode()
+	}
+	return f, nil
+}
+
+func (i *IOFSFileSystem) Stat(name string) (os.FileInfo, error) {
+	return fs.Stat(i.fsys, i.name(name))
+}
+
+func (i *IOFSFileSystem) ReadFile(name string) ([]byte, error) {
+	return fs.ReadFile(i.fsys, i.name(name))
+}
+
+func (i *IOFSFileSystem) WriteFile(name string, b []byte, perm os.FileMode) error {
+	return readOnlyError("open", name)
+}
+
+func (i *IOFSFileSystem) Remove(name string) error {
+	return readOnlyError("remove", name)
+}
+
+func (i *IOFSFileSystem) RemoveAll(name string) error {
+	return readOnlyError("remove", name)
+}
+
+func (i *IOFSFileSystem) Makedirs(name string) error {
+	return readOnlyError("mkdir", name)
+}
+
+func (i *IOFSFileSystem) Exists(name string) (bool, error) {
+	_, err := i.Stat(name)
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, fs.ErrNotExist):
+		return false, nil
+	}
+	return false, err
+}
+
+// Glob matches pattern the way every other filesystem does (see Glob).
+// Matches keep the leading slash if pattern has one.
+func (i *IOFSFileSystem) Glob(pattern string) ([]string, error) {
+	return Glob(i, pattern)
+}
+
+func (i *IOFSFileSystem) ReadDir(dirname string) ([]os.FileInfo, error) {
+	entries, err := fs.ReadDir(i.fsys, i.name(dirname))
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]os.FileInfo, len(entries))
+	for idx, entry := range entries {
+		if infos[idx], err = entry.Info(); err != nil {
+			return nil, err
+		}
+	}
+	return infos, nil
+}
+
+// iofsFile adapts an fs.File to FileInterface. Files that can't seek are
+// read into memory up front so that compression can be detected.
+type iofsFile struct {
+	name string
+	fs.File
+	seeker io.ReadSeeker
+}
+
+func newIOFSFile(name string, file fs.File) (*iofsFile, error) {
+	f := &iofsFile{name: name, File: file}
+	if seeker, ok := file.(io.ReadSeeker); ok {
+		f.seeker = seeker
+		return f, nil
+	}
+	if info, err := file.Stat(); err == nil && info.IsDir() {
+		// Reading a directory fails whichever way it is done
+		f.seeker = &dirReader{name}
+		return f, nil
+	}
+	b, err := io.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	f.seeker = bytes.NewReader(b)
+	return f, nil
+}
+
+func (f *iofsFile) Read(p []byte) (int, error) {
+	return f.seeker.Read(p)
+}
+
+func (f *iofsFile) Write(p []byte) (int, error) {
+	return 0, readOnlyError("write", f.name)
+}
+
+func (f *iofsFile) Seek(offset int64, whence int) (int64, error) {
+	return f.seeker.Seek(offset, whence)
+}
+
+type dirReader struct {
+	name string
+}
+
+func (d *dirReader) Read(p []byte) (int, error) {
+	return 0, &os.PathError{Op: "read", Path: d.name, Err: syscall.EISDIR}
+}
+
+func (d *dirReader) Seek(offset int64, whence int) (int64, error) {
+	return 0, nil
+}
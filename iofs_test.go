@@ -0,0 +1,120 @@
+package easyfiles
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/fs"
+	"os"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestToIOFS(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mem := NewMemFS()
+	require.Nil(mem.Makedirs("/root/sub/deeper"))
+	require.Nil(mem.WriteFile("/root/a.txt", []byte("a"), 0664))
+	require.Nil(mem.WriteFile("/root/sub/b.txt", []byte("bb"), 0664))
+	require.Nil(mem.WriteFile("/root/sub/deeper/c.gz", []byte("not really gzip"), 0664))
+	require.Nil(mem.WriteFile("/outside", []byte("x"), 0664))
+
+	fsys := ToIOFS(mem, "/root")
+	require.Nil(fstest.TestFS(fsys, "a.txt", "sub/b.txt", "sub/deeper/c.gz"))
+
+	// Files are served as stored
+	b, err := fs.ReadFile(fsys, "sub/deeper/c.gz")
+	require.Nil(err)
+	require.Equal("not really gzip", string(b))
+
+	matches, err := fs.Glob(fsys, "*/*.txt")
+	require.Nil(err)
+	require.Equal([]string{"sub/b.txt"}, matches)
+
+	_, err = fsys.Open("../outside")
+	require.True(errors.Is(err, fs.ErrInvalid))
+	_, err = fsys.Open("missing")
+	require.True(errors.Is(err, fs.ErrNotExist))
+}
+
+func TestToIOFSLocal(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir := t.TempDir()
+	require.Nil(LocalFS.WriteFile(dir+"/a", []byte("a"), 0664))
+	require.Nil(fstest.TestFS(ToIOFS(LocalFS, dir), "a"))
+}
+
+func TestToIOFSRelative(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Relative roots, including ".", list and glob like absolute ones
+	mem := NewMemFS()
+	require.Nil(mem.Makedirs("/data/sub"))
+	require.Nil(mem.WriteFile("/data/a.txt", []byte("a"), 0664))
+	require.Nil(mem.WriteFile("/data/sub/b.txt", []byte("b"), 0664))
+	require.Nil(fstest.TestFS(ToIOFS(mem, "data"), "a.txt", "sub/b.txt"))
+	require.Nil(fstest.TestFS(ToIOFS(mem, "."), "data/a.txt", "data/sub/b.txt"))
+	require.Nil(fstest.TestFS(ToIOFS(mem, "./data/"), "a.txt", "sub/b.txt"))
+}
+
+func TestFromIOFS(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	buf := bytes.NewBuffer(nil)
+	gz := gzip.NewWriter(buf)
+	gz.Write([]byte("hello\nworld\n"))
+	gz.Close()
+
+	mapFS := fstest.MapFS{
+		"plain.txt":        {Data: []byte("plain\n")},
+		"dir/data":         {Data: buf.Bytes()},
+		"dir/nested/x.txt": {Data: []byte("x")},
+	}
+	fsys := FromIOFS(mapFS)
+
+	// Compression is detected from the contents
+	f, err := fsys.Open("/dir/data", os.O_RDONLY, GZ_UNKNOWN)
+	require.Nil(err)
+	require.Equal(GZ_TRUE, f.Gz)
+	success, err := CheckFileContentsMatch(f, []byte("hello\nworld\n"), true, 0)
+	require.Nil(err)
+	require.True(success)
+	require.Nil(f.Close())
+
+	f, err = fsys.Open("plain.txt", os.O_RDONLY, GZ_UNKNOWN)
+	require.Nil(err)
+	require.Equal(GZ_FALSE, f.Gz)
+	require.Nil(f.Close())
+
+	_, err = fsys.Open("plain.txt", os.O_WRONLY, GZ_FALSE)
+	require.True(errors.Is(err, ErrReadOnly))
+	require.True(errors.Is(fsys.WriteFile("new", nil, 0664), ErrReadOnly))
+	require.True(errors.Is(fsys.Remove("plain.txt"), ErrReadOnly))
+
+	exists, err := fsys.Exists("/dir/nested")
+	require.Nil(err)
+	require.True(exists)
+	exists, err = fsys.Exists("/missing")
+	require.Nil(err)
+	require.False(exists)
+
+	infos, err := fsys.ReadDir("/dir")
+	require.Nil(err)
+	require.Equal(2, len(infos))
+	require.Equal("data", infos[0].Name())
+
+	matches, err := fsys.Glob("/**/*.txt")
+	require.Nil(err)
+	require.Equal([]string{"/dir/nested/x.txt", "/plain.txt"}, matches)
+
+	// And back again
+	require.Nil(fstest.TestFS(ToIOFS(fsys, "/"), "plain.txt", "dir/data", "dir/nested/x.txt"))
+}
@@ -0,0 +1,151 @@
+package easyfiles
+
+import (
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+)
+
+var ErrWriterClosed = errors.New("Writer is closed")
+
+// WriteLayer is a single stage of a writer stack. Write passes data on
+// towards the file, Flush pushes anything the layer buffers into the
+// layer below it and flushes that too, and Close finalises the layer
+// (trailers, last chunks, ..) before closing the layers below it. The
+// file itself is never closed by a layer.
+type WriteLayer interface {
+	io.Writer
+	Flusher
+	io.Closer
+}
+
+// LayerFunc stacks a new layer on top of next
+type LayerFunc func(next WriteLayer) (WriteLayer, error)
+
+// fileLayer is the bottom of every stack
+type fileLayer struct {
+	io.Writer
+}
+
+func (l fileLayer) Flush() error {
+	return nil
+}
+
+func (l fileLayer) Close() error {
+	return nil
+}
+
+type bufferLayer struct {
+	*bufio.Writer
+	next WriteLayer
+}
+
+// BufferLayer buffers writes in a buffer of size bytes. A size of 0 uses
+// bufio's default size.
+func BufferLayer(size int) LayerFunc {
+	return func(next WriteLayer) (WriteLayer, error) {
+		if size > 0 {
+			return &bufferLayer{bufio.NewWriterSize(next, size), next}, nil
+		}
+		return &bufferLayer{bufio.NewWriter(next), next}, nil
+	}
+}
+
+func (l *bufferLayer) Flush() error {
+	if err := l.Writer.Flush(); err != nil {
+		return err
+	}
+	return l.next.Flush()
+}
+
+func (l *bufferLayer) Close() error {
+	if err := l.Writer.Flush(); err != nil {
+		return err
+	}
+	return l.next.Close()
+}
+
+type gzipLayer struct {
+	*gzip.Writer
+	next WriteLayer
+}
+
+// GzipLayer compresses data with the given gzip compression level
+func GzipLayer(level int) LayerFunc {
+	return func(next WriteLayer) (WriteLayer, error) {
+		w, err := gzip.NewWriterLevel(next, level)
+		if err != nil {
+			return nil, err
+		}
+		return &gzipLayer{w, next}, nil
+	}
+}
+
+func (l *gzipLayer) Flush() error {
+	if err := l.Writer.Flush(); err != nil {
+		return err
+	}
+	return l.next.Flush()
+}
+
+func (l *gzipLayer) Close() error {
+	if err := l.Writer.Close(); err != nil {
+		return err
+	}
+	return l.next.Close()
+}
+
+type hashLayer struct {
+	hasher io.Writer
+	next   WriteLayer
+}
+
+// HashLayer feeds everything that passes through it into h
+func HashLayer(h *Hasher) LayerFunc {
+	return func(next WriteLayer) (WriteLayer, error) {
+		return &hashLayer{h, next}, nil
+	}
+}
+
+func (l *hashLayer) Write(p []byte) (int, error) {
+	n, err := l.next.Write(p)
+	l.hasher.Write(p[:n])
+	return n, err
+}
+
+func (l *hashLayer) Flush() error {
+	return l.next.Flush()
+}
+
+func (l *hashLayer) Close() error {
+	return l.next.Close()
+}
+
+type encryptLayer struct {
+	*EncryptWriter
+	next WriteLayer
+}
+
+// EncryptLayer encrypts data as described by enc. Since only whole chunks
+// can be encrypted, Flush does not push out a partially filled chunk.
+func EncryptLayer(enc *Encryption) LayerFunc {
+	return func(next WriteLayer) (WriteLayer, error) {
+		w, err := NewEncryptWriter(next, enc)
+		if err != nil {
+			return nil, err
+		}
+		return &encryptLayer{w, next}, nil
+	}
+}
+
+func (l *encryptLayer) Flush() error {
+	return l.next.Flush()
+}
+
+func (l *encryptLayer) Close() error {
+	if err := l.EncryptWriter.Close(); err != nil {
+		return err
+	}
+	return l.next.Close()
+}
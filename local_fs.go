@@ -3,8 +3,8 @@ package easyfiles
 import (
 	"io/ioutil"
 	"os"
-
-	"github.com/bmatcuk/doublestar"
+	"path/filepath"
+	"time"
 )
 
 type localFileSystem struct {
@@ -16,6 +16,26 @@ func (l localFileSystem) Open(name string, mode int, gz FileType) (*File, error)
 	return Open(name, mode, gz)
 }
 
+func (l localFileSystem) OpenFile(path string, o *OpenOptions) (*File, error) {
+	if err := o.Validate(); err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	if o.Parents {
+		if err := os.MkdirAll(filepath.Dir(path), o.DirPerm); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(path, o.OSFlags(), o.Perm)
+	if err != nil {
+		return nil, err
+	}
+	f := &File{Path: path, File: file, Mode: o.OSFlags(), Gz: o.Codec, Options: o}
+	if f.Gz == GZ_UNKNOWN {
+		f.FixMode()
+	}
+	return f, nil
+}
+
 func (l localFileSystem) Stat(name string) (os.FileInfo, error) {
 	return os.Stat(name)
 }
@@ -44,8 +64,47 @@ func (l localFileSystem) Exists(name string) (bool, error) {
 	return Exists(name), nil
 }
 
+func (l localFileSystem) Rename(oldpath, newpath string) error {
+	return os.Rename(oldpath, newpath)
+}
+
+func (l localFileSystem) Chmod(name string, mode os.FileMode) error {
+	return os.Chmod(name, mode)
+}
+
+func (l localFileSystem) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	return os.Chtimes(name, atime, mtime)
+}
+
+func (l localFileSystem) Truncate(name string, size int64) error {
+	return os.Truncate(name, size)
+}
+
+func (l localFileSystem) Symlink(oldname, newname string) error {
+	return os.Symlink(oldname, newname)
+}
+
+func (l localFileSystem) Readlink(name string) (string, error) {
+	return os.Readlink(name)
+}
+
+// syncDir fsyncs a directory so that entries created or renamed in it
+// survive a crash
+func (l localFileSystem) syncDir(dirname string) error {
+	dir, err := os.Open(dirname)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
+
+func (l localFileSystem) openDir(name string) (dirBatcher, error) {
+	return os.Open(name)
+}
+
 func (l localFileSystem) Glob(pattern string) ([]string, error) {
-	return doublestar.Glob(pattern)
+	return Glob(l, pattern)
 }
 
 func (l localFileSystem) ReadDir(dirname string) ([]os.FileInfo, error) {
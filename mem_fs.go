@@ -0,0 +1,522 @@
+package easyfiles
+
+import (
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MemFS is a FileSystemInterface that lives entirely in memory. It is
+// meant for tests that would otherwise hit the disk or need a live HDFS.
+// Paths are slash-separated; relative paths are resolved against "/".
+// Permission bits are recorded but not enforced.
+type MemFS struct {
+	mutex sync.RWMutex
+	root  *memNode
+}
+
+type memNode struct {
+	name    string
+	mode    os.FileMode
+	modTime time.Time
+	data    []byte
+	// children is nil for regular files
+	children map[string]*memNode
+}
+
+// NewMemFS returns an empty MemFS containing only the root directory
+func NewMemFS() *MemFS {
+	return &MemFS{root: newMemDir("/", DEFAULT_DIR_PERM)}
+}
+
+func newMemDir(name string, perm os.FileMode) *memNode {
+	return &memNode{
+		name:     name,
+		mode:     os.ModeDir | perm&os.ModePerm,
+		modTime:  time.Now(),
+		children: make(map[string]*memNode),
+	}
+}
+
+func (n *memNode) isDir() bool {
+	return n.children != nil
+}
+
+func (n *memNode) info() os.FileInfo {
+	return &memFileInfo{n.name, int64(len(n.data)), n.mode, n.modTime}
+}
+
+// memFileInfo is a snapshot of a memNode
+type memFileInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() os.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() interface{}   { return nil }
+
+func memClean(name string) string {
+	return path.Clean("/" + name)
+}
+
+// lookup returns the node at name along with its parent directory. If
+// the parent exists but name doesn't, node is nil and err is
+// os.ErrNotExist. Must be called with the mutex held.
+func (m *MemFS) lookup(op, name string) (parent *memNode, node *memNode, err error) {
+	if name == "" {
+		return nil, nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	clean := memClean(name)
+	if clean == "/" {
+		return nil, m.root, nil
+	}
+	node = m.root
+	components := strings.Split(clean[1:], "/")
+	for idx, component := range components {
+		if !node.isDir() {
+			return nil, nil, &os.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+		}
+		parent = node
+		node = parent.children[component]
+		if node == nil {
+			if idx < len(components)-1 {
+				parent = nil
+			}
+			return parent, nil, &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+		}
+	}
+	return parent, node, nil
+}
+
+func (m *MemFS) Open(name string, mode int, gz FileType) (*File, error) {
+	return m.open(name, mode, DEFAULT_FILE_PERM, gz, nil)
+}
+
+// OpenFile honours every OpenOption, including Perm
+func (m *MemFS) OpenFile(name string, o *OpenOptions) (*File, error) {
+	if err := o.Validate(); err != nil {
+		return nil, &os.PathError{Op: "open", Path: name, Err: err}
+	}
+	if o.Parents {
+		if err := m.mkdirAll(path.Dir(memClean(name)), o.DirPerm); err != nil {
+			return nil, err
+		}
+	}
+	return m.open(name, o.OSFlags(), o.Perm, o.Codec, o)
+}
+
+func (m *MemFS) open(name string, mode int, perm os.FileMode, gz FileType, o *OpenOptions) (*File, error) {
+	m.mutex.Lock()
+	parent, node, err := m.lookup("open", name)
+	writable := mode&(os.O_WRONLY|os.O_RDWR) != 0
+	switch {
+	case node != nil:
+		if mode&os.O_CREATE != 0 && mode&os.O_EXCL != 0 {
+			m.mutex.Unlock()
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+		}
+		if node.isDir() && writable {
+			m.mutex.Unlock()
+			return nil, &os.PathError{Op: "open", Path: name, Err: syscall.EISDIR}
+		}
+		if mode&os.O_TRUNC != 0 && writable {
+			node.data = nil
+			node.modTime = time.Now()
+		}
+	case parent != nil && mode&os.O_CREATE != 0:
+		node = &memNode{name: path.Base(memClean(name)), mode: perm & os.ModePerm, modTime: time.Now()}
+		parent.children[node.name] = node
+		parent.modTime = node.modTime
+	default:
+		m.mutex.Unlock()
+		return nil, err
+	}
+	m.mutex.Unlock()
+
+	file := &memFile{fs: m, node: node, path: name, mode: mode}
+	f := &File{Path: name, File: file, Mode: mode, Gz: gz, Options: o}
+	if gz == GZ_UNKNOWN {
+		f.FixMode()
+	}
+	return f, nil
+}
+
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	_, node, err := m.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return node.info(), nil
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	_, node, err := m.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if node.isDir() {
+		return nil, &os.PathError{Op: "read", Path: name, Err: syscall.EISDIR}
+	}
+	b := make([]byte, len(node.data))
+	copy(b, node.data)
+	return b, nil
+}
+
+func (m *MemFS) WriteFile(name string, b []byte, perm os.FileMode) error {
+	f, err := m.open(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm, GZ_FALSE, nil)
+	if err != nil {
+		return err
+	}
+	_, err = f.File.Write(b)
+	if e := f.Close(); err == nil {
+		err = e
+	}
+	return err
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	parent, node, err := m.lookup("remove", name)
+	if err != nil {
+		return err
+	}
+	if parent == nil {
+		return &os.PathError{Op: "remove", Path: name, Err: syscall.EBUSY}
+	}
+	if node.isDir() && len(node.children) > 0 {
+		return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+	}
+	delete(parent.children, node.name)
+	parent.modTime = time.Now()
+	return nil
+}
+
+// RemoveAll removes name and everything below it. Like os.RemoveAll, it
+// returns nil if name doesn't exist.
+func (m *MemFS) RemoveAll(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	parent, node, err := m.lookup("remove", name)
+	if node == nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if parent == nil {
+		// Removing the root empties it
+		node.children = make(map[string]*memNode)
+		node.modTime = time.Now()
+		return nil
+	}
+	delete(parent.children, node.name)
+	parent.modTime = time.Now()
+	return nil
+}
+
+// Makedirs creates name and any missing parents. Like the local
+// Makedirs, it does nothing if name already exists.
+func (m *MemFS) Makedirs(name string) error {
+	if exists, _ := m.Exists(name); exists {
+		return nil
+	}
+	return m.mkdirAll(name, DEFAULT_DIR_PERM)
+}
+
+func (m *MemFS) mkdirAll(name string, perm os.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	clean := memClean(name)
+	if clean == "/" {
+		return nil
+	}
+	node := m.root
+	for _, component := range strings.Split(clean[1:], "/") {
+		child := node.children[component]
+		if child == nil {
+			child = newMemDir(component, perm)
+			node.children[component] = child
+			node.modTime = child.modTime
+		} else if !child.isDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		node = child
+	}
+	return nil
+}
+
+func (m *MemFS) Exists(name string) (bool, error) {
+	if _, err := m.Stat(name); err != nil {
+		return false, nil
+	}
+	return true, nil
+}
+
+// Rename moves oldpath to newpath, replacing newpath if it is a file or
+// an empty directory
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	oldParent, node, err := m.lookup("rename", oldpath)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err.(*os.PathError).Err}
+	}
+	if oldParent == nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EBUSY}
+	}
+	oldClean, newClean := memClean(oldpath), memClean(newpath)
+	if oldClean == newClean {
+		return nil
+	}
+	if newClean == "/" {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EBUSY}
+	}
+	if node.isDir() && strings.HasPrefix(newClean, oldClean+"/") {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EINVAL}
+	}
+	newParent, existing, err := m.lookup("rename", newpath)
+	if newParent == nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err.(*os.PathError).Err}
+	}
+	if existing != nil {
+		switch {
+		case node.isDir() && !existing.isDir():
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.ENOTDIR}
+		case !node.isDir() && existing.isDir():
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EISDIR}
+		case existing.isDir() && len(existing.children) > 0:
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.ENOTEMPTY}
+		}
+	}
+	now := time.Now()
+	delete(oldParent.children, node.name)
+	oldParent.modTime = now
+	node.name = path.Base(newClean)
+	newParent.children[node.name] = node
+	newParent.modTime = now
+	return nil
+}
+
+// Copy copies src to dst without going through a File
+func (m *MemFS) Copy(src, dst string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, node, err := m.lookup("copy", src)
+	if err != nil {
+		return err
+	}
+	if node.isDir() {
+		return &os.PathError{Op: "copy", Path: src, Err: syscall.EISDIR}
+	}
+	parent, existing, err := m.lookup("copy", dst)
+	if parent == nil {
+		if existing != nil {
+			err = &os.PathError{Op: "copy", Path: dst, Err: syscall.EISDIR}
+		}
+		return err
+	}
+	if existing != nil && existing.isDir() {
+		return &os.PathError{Op: "copy", Path: dst, Err: syscall.EISDIR}
+	}
+	data := make([]byte, len(node.data))
+	copy(data, node.data)
+	if existing == nil {
+		existing = &memNode{name: path.Base(memClean(dst))}
+		parent.children[existing.name] = existing
+	}
+	existing.data = data
+	existing.mode = node.mode
+	existing.modTime = time.Now()
+	parent.modTime = existing.modTime
+	return nil
+}
+
+func (m *MemFS) Chmod(name string, mode os.FileMode) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, node, err := m.lookup("chmod", name)
+	if err != nil {
+		return err
+	}
+	node.mode = node.mode&^os.ModePerm | mode&os.ModePerm
+	return nil
+}
+
+// Chtimes sets the modification time of name. MemFS doesn't track access
+// times.
+func (m *MemFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, node, err := m.lookup("chtimes", name)
+	if err != nil {
+		return err
+	}
+	node.modTime = mtime
+	return nil
+}
+
+func (m *MemFS) Truncate(name string, size int64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	_, node, err := m.lookup("truncate", name)
+	switch {
+	case err != nil:
+		return err
+	case node.isDir():
+		return &os.PathError{Op: "truncate", Path: name, Err: syscall.EISDIR}
+	case size < 0:
+		return &os.PathError{Op: "truncate", Path: name, Err: syscall.EINVAL}
+	}
+	if size <= int64(len(node.data)) {
+		node.data = node.data[:size]
+	} else {
+		data := make([]byte, size)
+		copy(data, node.data)
+		node.data = data
+	}
+	node.modTime = time.Now()
+	return nil
+}
+
+// Glob returns the names of all files and directories matching pattern
+// (see Glob)
+func (m *MemFS) Glob(pattern string) ([]string, error) {
+	return Glob(m, pattern)
+}
+
+// ReadDir returns the entries of dirname sorted by name
+func (m *MemFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	_, node, err := m.lookup("open", dirname)
+	if err != nil {
+		return nil, err
+	}
+	if !node.isDir() {
+		return nil, &os.PathError{Op: "readdirent", Path: dirname, Err: syscall.ENOTDIR}
+	}
+	infos := make([]os.FileInfo, 0, len(node.children))
+	for _, child := range node.children {
+		infos = append(infos, child.info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+// memFile is an open handle on a memNode. Data is shared with the node,
+// so writes are visible to every other handle straight away.
+type memFile struct {
+	fs     *MemFS
+	node   *memNode
+	path   string
+	mode   int
+	offset int64
+	closed bool
+}
+
+func (f *memFile) Read(p []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	switch {
+	case f.closed:
+		return 0, &os.PathError{Op: "read", Path: f.path, Err: os.ErrClosed}
+	case f.mode&os.O_WRONLY != 0:
+		return 0, &os.PathError{Op: "read", Path: f.path, Err: syscall.EBADF}
+	case f.node.isDir():
+		return 0, &os.PathError{Op: "read", Path: f.path, Err: syscall.EISDIR}
+	case f.offset >= int64(len(f.node.data)):
+		if len(p) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	if f.closed {
+		return 0, &os.PathError{Op: "write", Path: f.path, Err: os.ErrClosed}
+	}
+	if f.mode&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return 0, &os.PathError{Op: "write", Path: f.path, Err: syscall.EBADF}
+	}
+	if f.mode&os.O_APPEND != 0 {
+		f.offset = int64(len(f.node.data))
+	}
+	end := f.offset + int64(len(p))
+	if end > int64(len(f.node.data)) {
+		if end > int64(cap(f.node.data)) {
+			data := make([]byte, end, 2*end)
+			copy(data, f.node.data)
+			f.node.data = data
+		} else {
+			old := len(f.node.data)
+			f.node.data = f.node.data[:end]
+			// Don't resurrect bytes left over from a truncation
+			for idx := old; idx < int(f.offset); idx++ {
+				f.node.data[idx] = 0
+			}
+		}
+	}
+	copy(f.node.data[f.offset:], p)
+	f.offset = end
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	if f.closed {
+		return 0, &os.PathError{Op: "seek", Path: f.path, Err: os.ErrClosed}
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += int64(len(f.node.data))
+	default:
+		return 0, &os.PathError{Op: "seek", Path: f.path, Err: syscall.EINVAL}
+	}
+	if offset < 0 {
+		return 0, &os.PathError{Op: "seek", Path: f.path, Err: syscall.EINVAL}
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+// Sync is a no-op; there is nowhere more durable to put the data
+func (f *memFile) Sync() error {
+	return nil
+}
+
+func (f *memFile) Close() error {
+	f.fs.mutex.Lock()
+	defer f.fs.mutex.Unlock()
+	if f.closed {
+		return &os.PathError{Op: "close", Path: f.path, Err: os.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
@@ -0,0 +1,137 @@
+package easyfiles
+
+import (
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemFSOpen(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fs := NewMemFS()
+	_, err := fs.Open("/a/out.gz", os.O_WRONLY|os.O_CREATE, GZ_UNKNOWN)
+	require.True(os.IsNotExist(err))
+
+	require.Nil(fs.Makedirs("/a"))
+	f, err := fs.Open("/a/out.gz", os.O_WRONLY|os.O_CREATE, GZ_UNKNOWN)
+	require.Nil(err)
+	require.Equal(GZ_TRUE, f.Gz)
+	w, err := f.Writer(0)
+	require.Nil(err)
+	w.Write([]byte("hello\nworld\n"))
+	require.Nil(f.Close())
+
+	// The stored bytes are compressed
+	b, err := fs.ReadFile("/a/out.gz")
+	require.Nil(err)
+	require.NotEqual("hello\nworld\n", string(b))
+
+	// Relative paths resolve against the root
+	f, err = fs.Open("a/out.gz", os.O_RDONLY, GZ_UNKNOWN)
+	require.Nil(err)
+	require.Equal(GZ_TRUE, f.Gz)
+	success, err := CheckFileContentsMatch(f, []byte("hello\nworld\n"), true, 0)
+	require.Nil(err)
+	require.True(success)
+	require.Nil(f.Close())
+
+	// Append without gzip
+	require.Nil(fs.WriteFile("/a/plain", []byte("abc"), 0600))
+	f, err = fs.Open("/a/plain", os.O_WRONLY|os.O_APPEND, GZ_FALSE)
+	require.Nil(err)
+	f.File.Write([]byte("def"))
+	_, err = f.File.Read(make([]byte, 1))
+	require.NotNil(err)
+	require.Nil(f.Close())
+	b, err = fs.ReadFile("/a/plain")
+	require.Nil(err)
+	require.Equal("abcdef", string(b))
+	info, err := fs.Stat("/a/plain")
+	require.Nil(err)
+	require.Equal(int64(6), info.Size())
+	require.Equal(os.FileMode(0600), info.Mode())
+
+	// Truncate and exclusive create
+	f, err = fs.Open("/a/plain", os.O_RDWR|os.O_TRUNC, GZ_FALSE)
+	require.Nil(err)
+	require.Nil(f.Close())
+	b, err = fs.ReadFile("/a/plain")
+	require.Nil(err)
+	require.Equal(0, len(b))
+	_, err = fs.Open("/a/plain", os.O_WRONLY|os.O_CREATE|os.O_EXCL, GZ_FALSE)
+	require.True(os.IsExist(err))
+
+	// Directories can't be written and files can't have children
+	_, err = fs.Open("/a", os.O_WRONLY, GZ_FALSE)
+	require.Equal(syscall.EISDIR, err.(*os.PathError).Err)
+	_, err = fs.Open("/a/plain/x", os.O_WRONLY|os.O_CREATE, GZ_FALSE)
+	require.Equal(syscall.ENOTDIR, err.(*os.PathError).Err)
+	require.NotNil(fs.Makedirs("/a/plain/x"))
+}
+
+func TestMemFSDirectories(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fs := NewMemFS()
+	require.Nil(fs.Makedirs("/x/y/z"))
+	require.Nil(fs.WriteFile("/x/b", []byte("b"), 0664))
+	require.Nil(fs.WriteFile("/x/a.txt", []byte("a"), 0664))
+	require.Nil(fs.WriteFile("/x/y/c.txt", []byte("c"), 0664))
+
+	infos, err := fs.ReadDir("/x")
+	require.Nil(err)
+	names := make([]string, 0)
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	require.Equal([]string{"a.txt", "b", "y"}, names)
+	require.True(infos[2].IsDir())
+	_, err = fs.ReadDir("/x/b")
+	require.NotNil(err)
+	_, err = fs.ReadDir("/nope")
+	require.True(os.IsNotExist(err))
+
+	matches, err := fs.Glob("/x/**/*.txt")
+	require.Nil(err)
+	require.Equal([]string{"/x/a.txt", "/x/y/c.txt"}, matches)
+	matches, err = fs.Glob("x/*")
+	require.Nil(err)
+	require.Equal([]string{"x/a.txt", "x/b", "x/y"}, matches)
+	_, err = fs.Glob("/x/[")
+	require.NotNil(err)
+
+	err = fs.Remove("/x/y")
+	require.Equal(syscall.ENOTEMPTY, err.(*os.PathError).Err)
+	require.True(os.IsNotExist(fs.Remove("/x/nope")))
+	require.Nil(fs.Remove("/x/b"))
+	require.Nil(fs.RemoveAll("/x/y"))
+	require.Nil(fs.RemoveAll("/x/y"))
+	exists, err := fs.Exists("/x/y/c.txt")
+	require.Nil(err)
+	require.False(exists)
+
+	require.Nil(fs.Rename("/x/a.txt", "/x/renamed"))
+	b, err := fs.ReadFile("/x/renamed")
+	require.Nil(err)
+	require.Equal("a", string(b))
+	require.NotNil(fs.Rename("/x", "/x/inside"))
+}
+
+func TestMemFSAtomic(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fs := NewMemFS()
+	require.Nil(WriteFileAtomic(fs, "/out", []byte("new"), 0664))
+	b, err := fs.ReadFile("/out")
+	require.Nil(err)
+	require.Equal("new", string(b))
+	infos, err := fs.ReadDir("/")
+	require.Nil(err)
+	require.Equal(1, len(infos))
+}
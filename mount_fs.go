@@ -0,0 +1,531 @@
+package easyfiles
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ErrNotMounted is returned when writing to a path no filesystem is
+// mounted at
+var ErrNotMounted = errors.New("No filesystem mounted")
+
+// CrossMountError is returned for renames between different mounts of a
+// MountFS. It unwraps to syscall.EXDEV, like a rename across devices.
+type CrossMountError struct {
+	Old string
+	New string
+}
+
+func (e *CrossMountError) Error() string {
+	return fmt.Sprintf("Cross-mount rename: %v -> %v", e.Old, e.New)
+}
+
+func (e *CrossMountError) Unwrap() error {
+	return syscall.EXDEV
+}
+
+type mount struct {
+	point string
+	fs    FileSystemInterface
+	root  string
+}
+
+// MountFS is a filesystem made of other filesystems mounted at path
+// prefixes, like a viewfs mount table. Paths are slash-separated and
+// absolute; relative paths are taken relative to /. A path belongs to the
+// mount with the longest matching prefix. Directories leading up to mount
+// points exist even if no filesystem holds them, and listings of a
+// directory include the mount points in it.
+type MountFS struct {
+	mutex sync.RWMutex
+	// Longest mount point first
+	mounts []*mount
+}
+
+// NewMountFS returns a MountFS with nothing mounted
+func NewMountFS() *MountFS {
+	return &MountFS{}
+}
+
+// Mount mounts the directory root of fs at point
+func (m *MountFS) Mount(point string, fs FileSystemInterface, root string) error {
+	point = memClean(point)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for _, mnt := range m.mounts {
+		if mnt.point == point {
+			return &os.PathError{Op: "mount", Path: point, Err: os.ErrExist}
+		}
+	}
+	m.mounts = append(m.mounts, &mount{point, fs, root})
+	sort.SliceStable(m.mounts, func(i, j int) bool { return len(m.mounts[i].point) > len(m.mounts[j].point) })
+	return nil
+}
+
+// Unmount removes the filesystem mounted at point
+func (m *MountFS) Unmount(point string) error {
+	point = memClean(point)
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for idx, mnt := range m.mounts {
+		if mnt.point == point {
+			m.mounts = append(m.mounts[:idx], m.mounts[idx+1:]...)
+			return nil
+		}
+	}
+	return &os.PathError{Op: "unmount", Path: point, Err: ErrNotMounted}
+}
+
+// Mounts returns the mount points, sorted
+func (m *MountFS) Mounts() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	points := make([]string, len(m.mounts))
+	for idx, mnt := range m.mounts {
+		points[idx] = mnt.point
+	}
+	sort.Strings(points)
+	return points
+}
+
+func isUnder(name, dir string) bool {
+	return dir == "/" || name == dir || strings.HasPrefix(name, dir+"/")
+}
+
+// resolve returns the mount name is in and its path there
+func (m *MountFS) resolve(name string) (*mount, string, bool) {
+	if name == "" {
+		return nil, "", false
+	}
+	clean := memClean(name)
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, mnt := range m.mounts {
+		if isUnder(clean, mnt.point) {
+			return mnt, path.Join(mnt.root, strings.TrimPrefix(clean, mnt.point)), true
+		}
+	}
+	return nil, "", false
+}
+
+// busy reports whether name is a mount point or leads up to one, and so
+// can't be removed or renamed
+func (m *MountFS) busy(name string) bool {
+	if name == "" {
+		return false
+	}
+	clean := memClean(name)
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	for _, mnt := range m.mounts {
+		if isUnder(mnt.point, clean) {
+			return true
+		}
+	}
+	return false
+}
+
+// mountsIn returns the entries of dir that are mount points or lead up to
+// them, by name. Mount points map to their mount, the rest to nil.
+func (m *MountFS) mountsIn(dir string) map[string]*mount {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+	prefix := strings.TrimSuffix(dir, "/") + "/"
+	ret := make(map[string]*mount)
+	for _, mnt := range m.mounts {
+		if mnt.point == dir || !strings.HasPrefix(mnt.point, prefix) {
+			continue
+		}
+		rest := mnt.point[len(prefix):]
+		if idx := strings.Index(rest, "/"); idx >= 0 {
+			if _, ok := ret[rest[:idx]]; !ok {
+				ret[rest[:idx]] = nil
+			}
+		} else {
+			ret[rest] = mnt
+		}
+	}
+	return ret
+}
+
+// mountInfo is the FileInfo of a mounted directory, named after its
+// mount point
+type mountInfo struct {
+	os.FileInfo
+	name string
+}
+
+func (i *mountInfo) Name() string {
+	return i.name
+}
+
+func syntheticDirInfo(name string) os.FileInfo {
+	return &memFileInfo{name, 0, os.ModeDir | 0555, time.Time{}}
+}
+
+func (m *MountFS) resolveWrite(op, name string) (*mount, string, error) {
+	mnt, inner, ok := m.resolve(name)
+	if !ok {
+		return nil, "", &os.PathError{Op: op, Path: name, Err: ErrNotMounted}
+	}
+	return mnt, inner, nil
+}
+
+func (m *MountFS) resolveRead(op, name string) (*mount, string, error) {
+	mnt, inner, ok := m.resolve(name)
+	if !ok {
+		return nil, "", &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+	}
+	return mnt, inner, nil
+}
+
+func (m *MountFS) Open(name string, mode int, gz FileType) (*File, error) {
+	resolve := m.resolveRead
+	if mode&(os.O_WRONLY|os.O_RDWR|os.O_CREATE) != 0 {
+		resolve = m.resolveWrite
+	}
+	mnt, inner, err := resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := mnt.fs.Open(inner, mode, gz)
+	if err != nil {
+		return nil, err
+	}
+	f.Path = name
+	return f, nil
+}
+
+// OpenFile honours every option the mounted filesystem honours
+func (m *MountFS) OpenFile(name string, o *OpenOptions) (*File, error) {
+	resolve := m.resolveRead
+	if o.Flags&(OPEN_WRITE|OPEN_APPEND|OPEN_CREATE|OPEN_TRUNCATE) != 0 {
+		resolve = m.resolveWrite
+	}
+	mnt, inner, err := resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := openFile(mnt.fs, inner, o)
+	if err != nil {
+		return nil, err
+	}
+	f.Path = name
+	return f, nil
+}
+
+// Stat returns the FileInfo of the mounted directory for mount points,
+// and a read-only directory for directories leading up to mount points
+// that no filesystem holds
+func (m *MountFS) Stat(name string) (os.FileInfo, error) {
+	clean := memClean(name)
+	mnt, inner, ok := m.resolve(name)
+	if ok {
+		info, err := statPath(mnt.fs, inner)
+		switch {
+		case err == nil && mnt.point == clean:
+			return &mountInfo{info, path.Base(clean)}, nil
+		case err == nil:
+			return info, nil
+		case !os.IsNotExist(err) || !m.busy(name):
+			return nil, err
+		}
+	}
+	if m.busy(name) {
+		return syntheticDirInfo(path.Base(clean)), nil
+	}
+	return nil, &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+}
+
+func (m *MountFS) ReadFile(name string) ([]byte, error) {
+	mnt, inner, err := m.resolveRead("open", name)
+	if err != nil {
+		return nil, err
+	}
+	return mnt.fs.ReadFile(inner)
+}
+
+func (m *MountFS) WriteFile(name string, b []byte, perm os.FileMode) error {
+	mnt, inner, err := m.resolveWrite("open", name)
+	if err != nil {
+		return err
+	}
+	return mnt.fs.WriteFile(inner, b, perm)
+}
+
+// Remove fails with EBUSY for mount points and the directories leading
+// up to them
+func (m *MountFS) Remove(name string) error {
+	if m.busy(name) {
+		return &os.PathError{Op: "remove", Path: name, Err: syscall.EBUSY}
+	}
+	mnt, inner, err := m.resolveRead("remove", name)
+	if err != nil {
+		return err
+	}
+	return mnt.fs.Remove(inner)
+}
+
+// RemoveAll fails with EBUSY for mount points and the directories leading
+// up to them
+func (m *MountFS) RemoveAll(name string) error {
+	if m.busy(name) {
+		return &os.PathError{Op: "removeall", Path: name, Err: syscall.EBUSY}
+	}
+	mnt, inner, ok := m.resolve(name)
+	if !ok {
+		return nil
+	}
+	return mnt.fs.RemoveAll(inner)
+}
+
+func (m *MountFS) Makedirs(name string) error {
+	mnt, inner, ok := m.resolve(name)
+	if ok {
+		return mnt.fs.Makedirs(inner)
+	}
+	if m.busy(name) {
+		return nil
+	}
+	return &os.PathError{Op: "mkdir", Path: name, Err: ErrNotMounted}
+}
+
+func (m *MountFS) Exists(name string) (bool, error) {
+	_, err := m.Stat(name)
+	switch {
+	case err == nil:
+		return true, nil
+	case os.IsNotExist(err):
+		return false, nil
+	}
+	return false, err
+}
+
+// Glob matches across mounts (see Glob)
+func (m *MountFS) Glob(pattern string) ([]string, error) {
+	return Glob(m, pattern)
+}
+
+// ReadDir returns the entries of dirname sorted by name, with the mount
+// points in it in place of whatever the filesystem holding dirname has by
+// the same names
+func (m *MountFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	clean := memClean(dirname)
+	entries := make(map[string]os.FileInfo)
+	mnt, inner, ok := m.resolve(dirname)
+	busy := m.busy(dirname)
+	if ok {
+		infos, err := mnt.fs.ReadDir(inner)
+		if err != nil && !(busy && os.IsNotExist(err)) {
+			return nil, err
+		}
+		for _, info := range infos {
+			entries[info.Name()] = info
+		}
+	} else if !busy {
+		return nil, &os.PathError{Op: "open", Path: dirname, Err: os.ErrNotExist}
+	}
+
+	for name, child := range m.mountsIn(clean) {
+		if child == nil {
+			if _, ok := entries[name]; !ok {
+				entries[name] = syntheticDirInfo(name)
+			}
+			continue
+		}
+		info, err := statPath(child.fs, child.root)
+		if err != nil {
+			info = syntheticDirInfo(name)
+		}
+		entries[name] = &mountInfo{info, name}
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, info := range entries {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+// Rename works within a mount, and fails with a *CrossMountError between
+// mounts
+func (m *MountFS) Rename(oldpath, newpath string) error {
+	if m.busy(oldpath) || m.busy(newpath) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EBUSY}
+	}
+	oldMount, oldInner, ok := m.resolve(oldpath)
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: os.ErrNotExist}
+	}
+	newMount, newInner, ok := m.resolve(newpath)
+	if !ok {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: ErrNotMounted}
+	}
+	if oldMount != newMount {
+		return &CrossMountError{oldpath, newpath}
+	}
+	return Rename(oldMount.fs, oldInner, newInner)
+}
+
+// Copy copies within a mount the way its filesystem does, and streams the
+// data between mounts
+func (m *MountFS) Copy(src, dst string) error {
+	srcMount, srcInner, err := m.resolveRead("copy", src)
+	if err != nil {
+		return err
+	}
+	dstMount, dstInner, err := m.resolveWrite("copy", dst)
+	if err != nil {
+		return err
+	}
+	if srcMount == dstMount {
+		return Copy(srcMount.fs, srcInner, dstInner)
+	}
+
+	info, err := statPath(srcMount.fs, srcInner)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return &os.PathError{Op: "copy", Path: src, Err: errors.New("is a directory")}
+	}
+	// Mounts may share a filesystem
+	if sameFile(info, dstMount.fs, dstInner) {
+		return &os.LinkError{Op: "copy", Old: src, New: dst, Err: ErrSameFile}
+	}
+	in, err := srcMount.fs.Open(srcInner, os.O_RDONLY, GZ_FALSE)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := dstMount.fs.Open(dstInner, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, GZ_FALSE)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(out.File, in.File)
+	if e := out.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return err
+	}
+	if _, ok := dstMount.fs.(Chmoder); ok {
+		return Chmod(dstMount.fs, dstInner, info.Mode().Perm())
+	}
+	return nil
+}
+
+func (m *MountFS) Chmod(name string, mode os.FileMode) error {
+	mnt, inner, err := m.resolveRead("chmod", name)
+	if err != nil {
+		return err
+	}
+	return Chmod(mnt.fs, inner, mode)
+}
+
+func (m *MountFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	mnt, inner, err := m.resolveRead("chtimes", name)
+	if err != nil {
+		return err
+	}
+	return Chtimes(mnt.fs, inner, atime, mtime)
+}
+
+func (m *MountFS) Truncate(name string, size int64) error {
+	mnt, inner, err := m.resolveRead("truncate", name)
+	if err != nil {
+		return err
+	}
+	return Truncate(mnt.fs, inner, size)
+}
+
+// Symlink creates newname on its mount. oldname is stored as it is, and
+// is resolved by the mounted filesystem, not the MountFS.
+func (m *MountFS) Symlink(oldname, newname string) error {
+	mnt, inner, err := m.resolveWrite("symlink", newname)
+	if err != nil {
+		return err
+	}
+	return Symlink(mnt.fs, oldname, inner)
+}
+
+func (m *MountFS) Readlink(name string) (string, error) {
+	mnt, inner, err := m.resolveRead("readlink", name)
+	if err != nil {
+		return "", err
+	}
+	return Readlink(mnt.fs, inner)
+}
+
+// MountTable describes a MountFS. In YAML:
+//
+//	mounts:
+//	  - path: /data
+//	    url: hdfs://nn:8020/warehouse
+//	  - path: /scratch
+//	    url: /tmp/scratch
+//	  - path: /fixtures
+//	    url: mem://fixtures/
+type MountTable struct {
+	Mounts []MountTableEntry `json:"mounts" yaml:"mounts"`
+}
+
+// MountTableEntry mounts the filesystem and directory URL refers to (see
+// ResolveURL) at Path
+type MountTableEntry struct {
+	Path string `json:"path" yaml:"path"`
+	URL  string `json:"url" yaml:"url"`
+}
+
+// ParseMountTable parses a mount table written in YAML or JSON
+func ParseMountTable(data []byte) (*MountTable, error) {
+	t := &MountTable{}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(t); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("Failed to parse mount table: %v", err)
+	}
+	for idx, entry := range t.Mounts {
+		if entry.Path == "" || entry.URL == "" {
+			return nil, fmt.Errorf("Failed to parse mount table: entry %d needs a path and a url", idx)
+		}
+	}
+	return t, nil
+}
+
+// LoadMountTable reads a mount table from the file at the URL or path
+// name
+func LoadMountTable(name string) (*MountTable, error) {
+	data, err := ReadFileURL(name)
+	if err != nil {
+		return nil, err
+	}
+	return ParseMountTable(data)
+}
+
+// NewMountFSFromTable returns a MountFS with everything in t mounted
+func NewMountFSFromTable(t *MountTable) (*MountFS, error) {
+	m := NewMountFS()
+	for _, entry := range t.Mounts {
+		fs, root, err := ResolveURL(entry.URL)
+		if err != nil {
+			return nil, err
+		}
+		if err := m.Mount(entry.Path, fs, root); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
@@ -0,0 +1,160 @@
+package easyfiles
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func names(infos []os.FileInfo) []string {
+	ret := make([]string, len(infos))
+	for idx, info := range infos {
+		ret[idx] = info.Name()
+	}
+	return ret
+}
+
+func TestMountFS(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	data := NewMemFS()
+	require.Nil(data.Makedirs("/warehouse/2024"))
+	require.Nil(data.WriteFile("/warehouse/2024/x.gz", []byte("data"), 0664))
+	fixtures := NewMemFS()
+	require.Nil(fixtures.WriteFile("/f.txt", []byte("fixture"), 0664))
+	scratch := t.TempDir()
+
+	m := NewMountFS()
+	require.Nil(m.Mount("/data", data, "/warehouse"))
+	require.Nil(m.Mount("/scratch", LocalFS, scratch))
+	require.Nil(m.Mount("/data/fixtures", fixtures, "/"))
+	require.True(os.IsExist(m.Mount("/data/", fixtures, "/")))
+	require.Equal([]string{"/data", "/data/fixtures", "/scratch"}, m.Mounts())
+
+	// Reads and writes go to the right filesystem
+	b, err := m.ReadFile("/data/2024/x.gz")
+	require.Nil(err)
+	require.Equal("data", string(b))
+	b, err = m.ReadFile("/data/fixtures/f.txt")
+	require.Nil(err)
+	require.Equal("fixture", string(b))
+	require.Nil(m.WriteFile("/scratch/out.txt", []byte("out"), 0664))
+	b, err = LocalFS.ReadFile(filepath.Join(scratch, "out.txt"))
+	require.Nil(err)
+	require.Equal("out", string(b))
+
+	// Listings merge mount points in
+	infos, err := m.ReadDir("/")
+	require.Nil(err)
+	require.Equal([]string{"data", "scratch"}, names(infos))
+	require.True(infos[0].IsDir())
+	infos, err = m.ReadDir("/data")
+	require.Nil(err)
+	require.Equal([]string{"2024", "fixtures"}, names(infos))
+	info, err := m.Stat("/data/fixtures")
+	require.Nil(err)
+	require.Equal("fixtures", info.Name())
+	require.True(info.IsDir())
+	matches, err := m.Glob("/**/*.{gz,txt}")
+	require.Nil(err)
+	require.Equal([]string{"/data/2024/x.gz", "/data/fixtures/f.txt", "/scratch/out.txt"}, matches)
+
+	// Outside every mount
+	_, err = m.Stat("")
+	require.True(os.IsNotExist(err))
+	_, err = m.Stat("/elsewhere")
+	require.True(os.IsNotExist(err))
+	exists, err := m.Exists("/elsewhere")
+	require.Nil(err)
+	require.False(exists)
+	require.True(errors.Is(m.WriteFile("/x.txt", nil, 0664), ErrNotMounted))
+	_, err = OpenFile(m, "/elsewhere", WithRead())
+	require.True(os.IsNotExist(err))
+	_, err = OpenFile(m, "/x.txt", WithWrite(), WithCreate())
+	require.True(errors.Is(err, ErrNotMounted))
+	require.Nil(m.Makedirs("/"))
+
+	// Mount points can't be removed or renamed
+	require.True(errors.Is(m.Remove("/data/fixtures"), syscall.EBUSY))
+	require.True(errors.Is(m.RemoveAll("/data"), syscall.EBUSY))
+	require.True(errors.Is(m.Rename("/data/fixtures", "/data/f"), syscall.EBUSY))
+
+	// Renames stay within a mount; copies don't have to
+	require.Nil(m.Rename("/data/2024/x.gz", "/data/2024/y.gz"))
+	exists, err = data.Exists("/warehouse/2024/y.gz")
+	require.Nil(err)
+	require.True(exists)
+	err = m.Rename("/data/2024/y.gz", "/scratch/y.gz")
+	cross := &CrossMountError{}
+	require.True(errors.As(err, &cross))
+	require.Equal("/scratch/y.gz", cross.New)
+	require.True(errors.Is(err, syscall.EXDEV))
+	require.Nil(Copy(m, "/data/2024/y.gz", "/scratch/y.gz"))
+	b, err = LocalFS.ReadFile(filepath.Join(scratch, "y.gz"))
+	require.Nil(err)
+	require.Equal("data", string(b))
+
+	f, err := OpenFile(m, "/data/new/z.txt", WithWrite(), WithCreate(), WithParents(0775))
+	require.Nil(err)
+	require.Equal("/data/new/z.txt", f.Path)
+	require.Nil(f.Close())
+
+	require.Nil(m.Unmount("/data/fixtures"))
+	_, err = m.Stat("/data/fixtures")
+	require.True(os.IsNotExist(err))
+	require.NotNil(m.Unmount("/data/fixtures"))
+}
+
+func TestMountTable(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	scratch := t.TempDir()
+	require.Nil(MakedirsURL("mem://test-mount-table/fixtures"))
+	require.Nil(WriteFileURL("mem://test-mount-table/fixtures/f.txt", []byte("f"), 0664))
+
+	yamlTable := []byte(`
+mounts:
+  - path: /scratch
+    url: ` + scratch + `
+  - path: /fixtures
+    url: mem://test-mount-table/fixtures
+`)
+	jsonTable := []byte(`{"mounts": [
+		{"path": "/scratch", "url": "file://` + scratch + `"},
+		{"path": "/fixtures", "url": "mem://test-mount-table/fixtures"}
+	]}`)
+	for idx, data := range [][]byte{yamlTable, jsonTable} {
+		name := filepath.Join(scratch, []string{"mounts.yaml", "mounts.json"}[idx])
+		require.Nil(LocalFS.WriteFile(name, data, 0664))
+		table, err := LoadMountTable(name)
+		require.Nil(err)
+		m, err := NewMountFSFromTable(table)
+		require.Nil(err)
+		require.Equal([]string{"/fixtures", "/scratch"}, m.Mounts())
+		b, err := m.ReadFile("/fixtures/f.txt")
+		require.Nil(err)
+		require.Equal("f", string(b))
+		infos, err := m.ReadDir("/scratch")
+		require.Nil(err)
+		require.Equal(idx+1, len(infos))
+	}
+
+	for _, bad := range []string{
+		"mounts: [{path: /x}]",
+		"mounts: [{path: /x, url: /y, extra: 1}]",
+		"{not yaml",
+	} {
+		_, err := ParseMountTable([]byte(bad))
+		require.NotNil(err, bad)
+	}
+	table, err := ParseMountTable([]byte("mounts: [{path: /x, url: 'nope://a/'}]"))
+	require.Nil(err)
+	_, err = NewMountFSFromTable(table)
+	require.NotNil(err)
+}
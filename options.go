@@ -0,0 +1,282 @@
+package easyfiles
+
+import (
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+type OpenFlag int
+
+const (
+	OPEN_READ OpenFlag = 1 << iota
+	OPEN_WRITE
+	OPEN_APPEND
+	OPEN_CREATE
+	OPEN_EXCLUSIVE
+	OPEN_TRUNCATE
+)
+
+const (
+	DEFAULT_FILE_PERM = 0664
+	DEFAULT_DIR_PERM  = 0775
+)
+
+// OpenOptions describes how OpenFile opens a file
+type OpenOptions struct {
+	Flags OpenFlag
+	// Perm is used when the file is created
+	Perm os.FileMode
+	// Parents creates missing parent directories with DirPerm
+	Parents bool
+	DirPerm os.FileMode
+	// Codec is the FileType of the file
+	Codec FileType
+	// BufSize and Level are the defaults for writers handed out by the file
+	BufSize int
+	Level   int
+}
+
+type OpenOption func(*OpenOptions)
+
+// InvalidOptionsError is returned for combinations of options that don't
+// make sense, on every filesystem
+type InvalidOptionsError struct {
+	Reason string
+}
+
+func (e *InvalidOptionsError) Error() string {
+	return fmt.Sprintf("Invalid open options: %v", e.Reason)
+}
+
+// WithRead opens the file for reading
+func WithRead() OpenOption {
+	return func(o *OpenOptions) { o.Flags |= OPEN_READ }
+}
+
+// WithWrite opens the file for writing
+func WithWrite() OpenOption {
+	return func(o *OpenOptions) { o.Flags |= OPEN_WRITE }
+}
+
+// WithAppend opens the file for writing at its end
+func WithAppend() OpenOption {
+	return func(o *OpenOptions) { o.Flags |= OPEN_WRITE | OPEN_APPEND }
+}
+
+// WithCreate creates the file if it doesn't exist
+func WithCreate() OpenOption {
+	return func(o *OpenOptions) { o.Flags |= OPEN_CREATE }
+}
+
+// WithExclusive fails if the file already exists. Requires WithCreate.
+func WithExclusive() OpenOption {
+	return func(o *OpenOptions) { o.Flags |= OPEN_EXCLUSIVE }
+}
+
+// WithTruncate truncates the file when it is opened
+func WithTruncate() OpenOption {
+	return func(o *OpenOptions) { o.Flags |= OPEN_TRUNCATE }
+}
+
+// WithFlags adds flags to the file's flags
+func WithFlags(flags OpenFlag) OpenOption {
+	return func(o *OpenOptions) { o.Flags |= flags }
+}
+
+// WithPerm sets the permission bits of a newly created file
+func WithPerm(perm os.FileMode) OpenOption {
+	return func(o *OpenOptions) { o.Perm = perm }
+}
+
+// WithParents creates missing parent directories with permission bits perm
+func WithParents(perm os.FileMode) OpenOption {
+	return func(o *OpenOptions) {
+		o.Parents = true
+		o.DirPerm = perm
+	}
+}
+
+// WithCodec sets the FileType of the file. The default is GZ_UNKNOWN.
+func WithCodec(codec FileType) OpenOption {
+	return func(o *OpenOptions) { o.Codec = codec }
+}
+
+// WithBufferSize sets the buffer size of writers handed out by the file
+func WithBufferSize(size int) OpenOption {
+	return func(o *OpenOptions) { o.BufSize = size }
+}
+
+// WithCompressionLevel sets the gzip compression level of writers handed
+// out by the file
+func WithCompressionLevel(level int) OpenOption {
+	return func(o *OpenOptions) { o.Level = level }
+}
+
+// NewOpenOptions applies opts on top of the defaults
+func NewOpenOptions(opts ...OpenOption) *OpenOptions {
+	o := &OpenOptions{
+		Perm:    DEFAULT_FILE_PERM,
+		DirPerm: DEFAULT_DIR_PERM,
+		Codec:   GZ_UNKNOWN,
+		Level:   gzip.DefaultCompression,
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Validate checks that the options make sense together
+func (o *OpenOptions) Validate() error {
+	if o.Flags&(OPEN_READ|OPEN_WRITE) == 0 {
+		return &InvalidOptionsError{"neither read nor write requested"}
+	}
+	writable := o.Flags&OPEN_WRITE != 0
+	if o.Flags&OPEN_APPEND != 0 && !writable {
+		return &InvalidOptionsError{"append requires write"}
+	}
+	if o.Flags&OPEN_CREATE != 0 && !writable {
+		return &InvalidOptionsError{"create requires write"}
+	}
+	if o.Flags&OPEN_TRUNCATE != 0 && !writable {
+		return &InvalidOptionsError{"truncate requires write"}
+	}
+	if o.Flags&OPEN_TRUNCATE != 0 && o.Flags&OPEN_APPEND != 0 {
+		return &InvalidOptionsError{"truncate and append are mutually exclusive"}
+	}
+	if o.Flags&OPEN_EXCLUSIVE != 0 && o.Flags&OPEN_CREATE == 0 {
+		return &InvalidOptionsError{"exclusive requires create"}
+	}
+	if o.Perm&^os.ModePerm != 0 || o.DirPerm&^os.ModePerm != 0 {
+		return &InvalidOptionsError{"permissions may only contain permission bits"}
+	}
+	switch o.Codec {
+	case GZ_TRUE, GZ_FALSE, GZ_UNKNOWN:
+	default:
+		return &InvalidOptionsError{fmt.Sprintf("unknown codec %d", o.Codec)}
+	}
+	if o.BufSize < 0 {
+		return &InvalidOptionsError{"negative buffer size"}
+	}
+	if o.Level < gzip.HuffmanOnly || o.Level > gzip.BestCompression {
+		return &InvalidOptionsError{fmt.Sprintf("compression level %d out of range", o.Level)}
+	}
+	return nil
+}
+
+// OSFlags translates the options into flags for os.OpenFile
+func (o *OpenOptions) OSFlags() int {
+	var mode int
+	switch {
+	case o.Flags&OPEN_READ != 0 && o.Flags&OPEN_WRITE != 0:
+		mode = os.O_RDWR
+	case o.Flags&OPEN_WRITE != 0:
+		mode = os.O_WRONLY
+	default:
+		mode = os.O_RDONLY
+	}
+	if o.Flags&OPEN_APPEND != 0 {
+		mode |= os.O_APPEND
+	}
+	if o.Flags&OPEN_CREATE != 0 {
+		mode |= os.O_CREATE
+	}
+	if o.Flags&OPEN_EXCLUSIVE != 0 {
+		mode |= os.O_EXCL
+	}
+	if o.Flags&OPEN_TRUNCATE != 0 {
+		mode |= os.O_TRUNC
+	}
+	return mode
+}
+
+// OpenFiler is implemented by filesystems that honour every OpenOption.
+// Filesystems that don't are opened through Open, and the files and
+// parent directories they create are given Perm and DirPerm with Chmod.
+// Without Chmoder, asking them for anything but DEFAULT_FILE_PERM and
+// DEFAULT_DIR_PERM fails with ErrNotSupported.
+type OpenFiler interface {
+	OpenFile(path string, o *OpenOptions) (*File, error)
+}
+
+// OpenFile opens path on fs as described by opts. Options are validated
+// the same way regardless of filesystem.
+func OpenFile(fs FileSystemInterface, path string, opts ...OpenOption) (*File, error) {
+	o := NewOpenOptions(opts...)
+	if err := o.Validate(); err != nil {
+		return nil, &os.PathError{Op: "open", Path: path, Err: err}
+	}
+	return openFile(fs, path, o)
+}
+
+// openFile opens path with options that have already been validated
+func openFile(fs FileSystemInterface, path string, o *OpenOptions) (*File, error) {
+	if of, ok := fs.(OpenFiler); ok {
+		return of.OpenFile(path, o)
+	}
+	// Only files this creates are given Perm
+	chmod := o.Flags&OPEN_CREATE != 0 && o.Perm != DEFAULT_FILE_PERM
+	if chmod {
+		if _, ok := fs.(Chmoder); !ok {
+			return nil, &os.PathError{Op: "open", Path: path, Err: ErrNotSupported}
+		}
+		exists, err := fs.Exists(path)
+		if err != nil {
+			return nil, err
+		}
+		chmod = !exists
+	}
+	if o.Parents {
+		if err := makeParents(fs, filepath.Dir(path), o.DirPerm); err != nil {
+			return nil, err
+		}
+	}
+	f, err := fs.Open(path, o.OSFlags(), o.Codec)
+	if err != nil {
+		return nil, err
+	}
+	if chmod {
+		if err := Chmod(fs, path, o.Perm); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	f.Options = o
+	return f, nil
+}
+
+// makeParents creates dir and any missing directories above it on fs,
+// giving the ones it creates perm
+func makeParents(fs FileSystemInterface, dir string, perm os.FileMode) error {
+	if perm == DEFAULT_DIR_PERM {
+		return fs.Makedirs(dir)
+	}
+	if _, ok := fs.(Chmoder); !ok {
+		return &os.PathError{Op: "mkdir", Path: dir, Err: ErrNotSupported}
+	}
+	missing := make([]string, 0)
+	for d := dir; ; d = filepath.Dir(d) {
+		exists, err := fs.Exists(d)
+		if err != nil {
+			return err
+		}
+		if exists {
+			break
+		}
+		missing = append(missing, d)
+		if filepath.Dir(d) == d {
+			break
+		}
+	}
+	if err := fs.Makedirs(dir); err != nil {
+		return err
+	}
+	for idx := len(missing) - 1; idx >= 0; idx-- {
+		if err := Chmod(fs, missing[idx], perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
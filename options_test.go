@@ -0,0 +1,147 @@
+package easyfiles
+
+import (
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenOptionsValidate(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	invalid := [][]OpenOption{
+		{},
+		{WithRead(), WithCreate()},
+		{WithRead(), WithTruncate()},
+		{WithAppend(), WithTruncate()},
+		{WithWrite(), WithExclusive()},
+		{WithWrite(), WithPerm(os.ModeDir | 0644)},
+		{WithWrite(), WithCodec(FileType(42))},
+		{WithWrite(), WithBufferSize(-1)},
+		{WithWrite(), WithCompressionLevel(10)},
+	}
+	for idx, opts := range invalid {
+		err := NewOpenOptions(opts...).Validate()
+		require.NotNil(err, "case %d", idx)
+		_, ok := err.(*InvalidOptionsError)
+		require.True(ok, "case %d", idx)
+	}
+
+	o := NewOpenOptions(WithAppend(), WithCreate())
+	require.Nil(o.Validate())
+	require.Equal(os.O_WRONLY|os.O_APPEND|os.O_CREATE, o.OSFlags())
+	o = NewOpenOptions(WithRead(), WithWrite(), WithCreate(), WithExclusive())
+	require.Equal(os.O_RDWR|os.O_CREATE|os.O_EXCL, o.OSFlags())
+}
+
+func TestOpenFile(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "options")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	// Invalid options fail before touching the filesystem
+	fname := filepath.Join(dir, "a", "b", "out.gz")
+	_, err = OpenFile(LocalFS, fname, WithRead(), WithCreate())
+	require.NotNil(err)
+	_, err = os.Stat(filepath.Join(dir, "a"))
+	require.True(os.IsNotExist(err))
+
+	f, err := OpenFile(LocalFS, fname, WithWrite(), WithCreate(), WithExclusive(),
+		WithPerm(0600), WithParents(0700), WithCodec(GZ_TRUE),
+		WithBufferSize(16), WithCompressionLevel(gzip.BestSpeed))
+	require.Nil(err)
+	require.Equal(GZ_TRUE, f.Gz)
+	w, err := f.Writer(0)
+	require.Nil(err)
+	_, err = w.Write([]byte("hello"))
+	require.Nil(err)
+	require.Nil(f.Close())
+
+	info, err := os.Stat(fname)
+	require.Nil(err)
+	require.Equal(os.FileMode(0600), info.Mode().Perm())
+	info, err = os.Stat(filepath.Dir(fname))
+	require.Nil(err)
+	require.Equal(os.FileMode(0700), info.Mode().Perm())
+
+	// Exclusive refuses to open an existing file
+	_, err = OpenFile(LocalFS, fname, WithWrite(), WithCreate(), WithExclusive())
+	require.True(os.IsExist(err))
+
+	f, err = OpenFile(LocalFS, fname, WithRead())
+	require.Nil(err)
+	defer f.Close()
+	require.Equal(GZ_TRUE, f.Gz)
+	success, err := CheckFileContentsMatch(f, []byte("hello"), true, 0)
+	require.Nil(err)
+	require.True(success)
+}
+
+// chmodOnlyFS can chmod, but not open with options
+type chmodOnlyFS struct {
+	FileSystemInterface
+}
+
+func (c chmodOnlyFS) Chmod(name string, mode os.FileMode) error {
+	return Chmod(c.FileSystemInterface, name, mode)
+}
+
+func TestOpenFileFallbackPerm(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// Filesystems without OpenFiler still create files with Perm
+	mem := NewMemFS()
+	fs := chmodOnlyFS{mem}
+	f, err := OpenFile(fs, "/new", WithWrite(), WithCreate(), WithPerm(0600))
+	require.Nil(err)
+	require.Nil(f.Close())
+	info, err := mem.Stat("/new")
+	require.Nil(err)
+	require.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	// Existing files keep theirs
+	require.Nil(mem.WriteFile("/old", nil, 0644))
+	f, err = OpenFile(fs, "/old", WithWrite(), WithCreate(), WithPerm(0600))
+	require.Nil(err)
+	require.Nil(f.Close())
+	info, err = mem.Stat("/old")
+	require.Nil(err)
+	require.Equal(os.FileMode(0644), info.Mode().Perm())
+
+	// Parent directories created along the way get DirPerm
+	f, err = OpenFile(fs, "/old-dir/a/b/new", WithWrite(), WithCreate(), WithParents(0700))
+	require.Nil(err)
+	require.Nil(f.Close())
+	for _, dir := range []string{"/old-dir", "/old-dir/a", "/old-dir/a/b"} {
+		info, err = mem.Stat(dir)
+		require.Nil(err)
+		require.Equal(os.FileMode(0700), info.Mode().Perm(), dir)
+	}
+	require.Nil(Chmod(mem, "/old-dir", 0755))
+	f, err = OpenFile(fs, "/old-dir/c/new", WithWrite(), WithCreate(), WithParents(0700))
+	require.Nil(err)
+	require.Nil(f.Close())
+	info, err = mem.Stat("/old-dir")
+	require.Nil(err)
+	require.Equal(os.FileMode(0755), info.Mode().Perm())
+
+	// and without Chmoder, Perm and DirPerm can't be honoured at all
+	bare := struct{ FileSystemInterface }{mem}
+	_, err = OpenFile(bare, "/other", WithWrite(), WithCreate(), WithPerm(0600))
+	require.True(errors.Is(err, ErrNotSupported))
+	_, err = OpenFile(bare, "/dir/other", WithWrite(), WithCreate(), WithParents(0700))
+	require.True(errors.Is(err, ErrNotSupported))
+	f, err = OpenFile(bare, "/other", WithWrite(), WithCreate())
+	require.Nil(err)
+	require.Nil(f.Close())
+}
@@ -0,0 +1,575 @@
+package easyfiles
+
+import (
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	// Whiteouts are empty files in the upper layer that hide what the
+	// lower layer has by the same name, minus the prefix. They use the
+	// same names as OCI image layers.
+	WHITEOUT_PREFIX = ".wh."
+	// An opaque marker in a directory of the upper layer hides all of the
+	// lower layer's directory
+	OPAQUE_WHITEOUT = WHITEOUT_PREFIX + WHITEOUT_PREFIX + ".opq"
+	// Files being copied up are written under this prefix, which is
+	// reserved like any other whiteout name
+	COPY_UP_PREFIX = WHITEOUT_PREFIX + WHITEOUT_PREFIX + ".cp."
+)
+
+// OverlayFS is a union of a read-only lower layer and a writable upper
+// layer. Reads see the upper layer's files over the lower layer's.
+// Writes only ever go to the upper layer: files from the lower layer are
+// copied up before they are changed, and removing them leaves a whiteout
+// behind. Both layers are accessed with the same paths; to keep the upper
+// layer in a directory, mount that directory at / with a MountFS.
+//
+// Names starting with WHITEOUT_PREFIX are reserved. Directories that
+// exist in the lower layer can't be renamed (EXDEV), as with Linux's
+// overlayfs. The upper layer has to support Rename for files to be
+// copied up.
+type OverlayFS struct {
+	Lower FileSystemInterface
+	Upper FileSystemInterface
+	// Serializes changes, so that concurrent writes don't copy up the
+	// same file twice
+	mutex sync.Mutex
+}
+
+// NewOverlayFS returns an overlay of upper over lower
+func NewOverlayFS(lower, upper FileSystemInterface) *OverlayFS {
+	return &OverlayFS{Lower: lower, Upper: upper}
+}
+
+func isWhiteoutName(name string) bool {
+	return strings.HasPrefix(path.Base(name), WHITEOUT_PREFIX)
+}
+
+func whiteoutPath(name string) string {
+	dir, base := path.Split(name)
+	return dir + WHITEOUT_PREFIX + base
+}
+
+func (o *OverlayFS) upperInfo(name string) os.FileInfo {
+	info, err := statPath(o.Upper, name)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+func (o *OverlayFS) upperHas(name string) bool {
+	return o.upperInfo(name) != nil
+}
+
+// lowerVisible reports whether the lower layer's name, if it has one,
+// shows through: neither it nor any directory above it is whited out,
+// replaced by a file, or made opaque in the upper layer
+func (o *OverlayFS) lowerVisible(name string) bool {
+	name = path.Clean(name)
+	components := strings.Split(name, "/")
+	for idx := range components {
+		prefix := strings.Join(components[:idx+1], "/")
+		if prefix == "" {
+			// The root of an absolute path
+			prefix = "/"
+		} else if o.upperHas(whiteoutPath(prefix)) {
+			return false
+		}
+		if idx == len(components)-1 {
+			break
+		}
+		if info := o.upperInfo(prefix); info != nil && (!info.IsDir() || o.upperHas(path.Join(prefix, OPAQUE_WHITEOUT))) {
+			return false
+		}
+	}
+	return true
+}
+
+func (o *OverlayFS) lowerInfo(name string) os.FileInfo {
+	if !o.lowerVisible(name) {
+		return nil
+	}
+	info, err := statPath(o.Lower, name)
+	if err != nil {
+		return nil
+	}
+	return info
+}
+
+func (o *OverlayFS) notExist(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: os.ErrNotExist}
+}
+
+func (o *OverlayFS) reserved(op, name string) error {
+	return &os.PathError{Op: op, Path: name, Err: syscall.EINVAL}
+}
+
+// Stat returns the upper layer's FileInfo over the lower layer's
+func (o *OverlayFS) Stat(name string) (os.FileInfo, error) {
+	if name == "" || isWhiteoutName(name) {
+		return nil, o.notExist("stat", name)
+	}
+	if info := o.upperInfo(name); info != nil {
+		return info, nil
+	}
+	if info := o.lowerInfo(name); info != nil {
+		return info, nil
+	}
+	return nil, o.notExist("stat", name)
+}
+
+func (o *OverlayFS) Exists(name string) (bool, error) {
+	_, err := o.Stat(name)
+	switch {
+	case err == nil:
+		return true, nil
+	case os.IsNotExist(err):
+		return false, nil
+	}
+	return false, err
+}
+
+func (o *OverlayFS) ReadFile(name string) ([]byte, error) {
+	if _, err := o.Stat(name); err != nil {
+		return nil, err
+	}
+	if o.upperHas(name) {
+		return o.Upper.ReadFile(name)
+	}
+	return o.Lower.ReadFile(name)
+}
+
+// prepareParent makes sure the directory name is to be created in exists
+// in the upper layer
+func (o *OverlayFS) prepareParent(op, name string) error {
+	parent := path.Dir(name)
+	if o.upperHas(parent) {
+		return nil
+	}
+	info, err := o.Stat(parent)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &os.PathError{Op: op, Path: name, Err: syscall.ENOTDIR}
+	}
+	return o.copyUpDirs(parent)
+}
+
+// copyUpDirs recreates dir and the directories above it in the upper
+// layer, with the lower layer's permissions
+func (o *OverlayFS) copyUpDirs(dir string) error {
+	if o.upperHas(dir) {
+		return nil
+	}
+	if parent := path.Dir(dir); parent != dir {
+		if err := o.copyUpDirs(parent); err != nil {
+			return err
+		}
+	}
+	if err := o.Upper.Makedirs(dir); err != nil {
+		return err
+	}
+	if info := o.lowerInfo(dir); info != nil {
+		if _, ok := o.Upper.(Chmoder); ok {
+			return Chmod(o.Upper, dir, info.Mode().Perm())
+		}
+	}
+	return nil
+}
+
+// prepareCreate readies name to be created in the upper layer, clearing
+// any whiteout left by an earlier removal
+func (o *OverlayFS) prepareCreate(op, name string) error {
+	if name == "" {
+		return o.notExist(op, name)
+	}
+	if isWhiteoutName(name) {
+		return o.reserved(op, name)
+	}
+	if err := o.prepareParent(op, name); err != nil {
+		return err
+	}
+	return o.Upper.RemoveAll(whiteoutPath(name))
+}
+
+// copyUp copies the lower layer's file name to the upper layer, unless
+// the upper layer already has it. With data unset, only an empty file
+// with the same permissions is created, for callers about to truncate.
+// The copy is made under a reserved name and renamed into place, so
+// readers never see it half written.
+func (o *OverlayFS) copyUp(name string, data bool) error {
+	if o.upperHas(name) {
+		return nil
+	}
+	info := o.lowerInfo(name)
+	if info == nil {
+		return o.notExist("open", name)
+	}
+	if err := o.prepareParent("open", name); err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return o.copyUpDirs(name)
+	}
+
+	tmp := path.Join(path.Dir(name), COPY_UP_PREFIX+path.Base(name))
+	if err := o.copyUpTo(tmp, name, info, data); err != nil {
+		o.Upper.RemoveAll(tmp)
+		return err
+	}
+	return Rename(o.Upper, tmp, name)
+}
+
+// copyUpTo copies the lower layer's file name to tmp in the upper layer
+func (o *OverlayFS) copyUpTo(tmp, name string, info os.FileInfo, data bool) error {
+	out, err := o.Upper.Open(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, GZ_FALSE)
+	if err != nil {
+		return err
+	}
+	if data {
+		in, err := o.Lower.Open(name, os.O_RDONLY, GZ_FALSE)
+		if err != nil {
+			out.Close()
+			return err
+		}
+		_, err = io.Copy(out.File, in.File)
+		in.Close()
+		if err != nil {
+			out.Close()
+			return err
+		}
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	if _, ok := o.Upper.(Chmoder); ok {
+		if err := Chmod(o.Upper, tmp, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
+	if _, ok := o.Upper.(Chtimeser); ok && data {
+		return Chtimes(o.Upper, tmp, info.ModTime(), info.ModTime())
+	}
+	return nil
+}
+
+func isWriteMode(mode int) bool {
+	return mode&(os.O_WRONLY|os.O_RDWR|os.O_CREATE|os.O_APPEND|os.O_TRUNC) != 0
+}
+
+// prepareWrite readies name to be opened for writing in the upper layer
+func (o *OverlayFS) prepareWrite(name string, create, exclusive, truncate bool) error {
+	if _, err := o.Stat(name); err == nil && exclusive {
+		return &os.PathError{Op: "open", Path: name, Err: os.ErrExist}
+	} else if err != nil {
+		if !os.IsNotExist(err) || !create {
+			return err
+		}
+		return o.prepareCreate("open", name)
+	}
+	return o.copyUp(name, !truncate)
+}
+
+func (o *OverlayFS) Open(name string, mode int, gz FileType) (*File, error) {
+	if !isWriteMode(mode) {
+		if _, err := o.Stat(name); err != nil {
+			return nil, err
+		}
+		if o.upperHas(name) {
+			return o.Upper.Open(name, mode, gz)
+		}
+		return o.Lower.Open(name, mode, gz)
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if err := o.prepareWrite(name, mode&os.O_CREATE != 0, mode&os.O_EXCL != 0, mode&os.O_TRUNC != 0); err != nil {
+		return nil, err
+	}
+	return o.Upper.Open(name, mode, gz)
+}
+
+// OpenFile honours every option the upper layer honours. Files opened
+// only for reading come from whichever layer has them.
+func (o *OverlayFS) OpenFile(name string, opts *OpenOptions) (*File, error) {
+	if opts.Flags&(OPEN_WRITE|OPEN_APPEND|OPEN_CREATE|OPEN_TRUNCATE) == 0 {
+		if _, err := o.Stat(name); err != nil {
+			return nil, err
+		}
+		if o.upperHas(name) {
+			return openFile(o.Upper, name, opts)
+		}
+		return openFile(o.Lower, name, opts)
+	}
+
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if opts.Parents {
+		if err := o.makedirs(path.Dir(name), opts.DirPerm); err != nil {
+			return nil, err
+		}
+	}
+	if err := o.prepareWrite(name, opts.Flags&OPEN_CREATE != 0, opts.Flags&OPEN_EXCLUSIVE != 0, opts.Flags&OPEN_TRUNCATE != 0); err != nil {
+		return nil, err
+	}
+	return openFile(o.Upper, name, opts)
+}
+
+func (o *OverlayFS) WriteFile(name string, b []byte, perm os.FileMode) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if err := o.prepareWrite(name, true, false, true); err != nil {
+		return err
+	}
+	return o.Upper.WriteFile(name, b, perm)
+}
+
+// whiteout hides the lower layer's name, if it has one
+func (o *OverlayFS) whiteout(name string) error {
+	if o.lowerInfo(name) == nil {
+		return nil
+	}
+	if err := o.prepareParent("remove", name); err != nil {
+		return err
+	}
+	return o.Upper.WriteFile(whiteoutPath(name), nil, DEFAULT_FILE_PERM)
+}
+
+func (o *OverlayFS) Remove(name string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	info, err := o.Stat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		infos, err := o.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		if len(infos) > 0 {
+			return &os.PathError{Op: "remove", Path: name, Err: syscall.ENOTEMPTY}
+		}
+	}
+	if o.upperHas(name) {
+		if err := o.Upper.RemoveAll(name); err != nil {
+			return err
+		}
+	}
+	return o.whiteout(name)
+}
+
+func (o *OverlayFS) RemoveAll(name string) error {
+	if isWhiteoutName(name) {
+		return o.reserved("removeall", name)
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if o.upperHas(name) {
+		if err := o.Upper.RemoveAll(name); err != nil {
+			return err
+		}
+	}
+	return o.whiteout(name)
+}
+
+func (o *OverlayFS) Makedirs(name string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return o.makedirs(name, DEFAULT_DIR_PERM)
+}
+
+// makedirs creates name and any missing parents in the upper layer. A
+// directory recreated where the lower layer's was removed is made opaque,
+// so that the old contents stay hidden. The directories it creates are
+// given perm, as OpenFile's fallback does.
+func (o *OverlayFS) makedirs(name string, perm os.FileMode) error {
+	if name == "" {
+		return o.notExist("mkdir", name)
+	}
+	if isWhiteoutName(name) {
+		return o.reserved("mkdir", name)
+	}
+	if info, err := o.Stat(name); err == nil {
+		if !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+		return o.copyUpDirs(name)
+	}
+	if parent := path.Dir(name); parent != name {
+		if err := o.makedirs(parent, perm); err != nil {
+			return err
+		}
+	}
+	whitedOut := o.upperHas(whiteoutPath(name))
+	if err := o.Upper.RemoveAll(whiteoutPath(name)); err != nil {
+		return err
+	}
+	if err := makeParents(o.Upper, name, perm); err != nil {
+		return err
+	}
+	if whitedOut {
+		return o.Upper.WriteFile(path.Join(name, OPAQUE_WHITEOUT), nil, DEFAULT_FILE_PERM)
+	}
+	return nil
+}
+
+// ReadDir merges the entries of both layers, sorted by name
+func (o *OverlayFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	if _, err := o.Stat(dirname); err != nil {
+		return nil, err
+	}
+	entries := make(map[string]os.FileInfo)
+	hidden := make(map[string]bool)
+	lower := o.lowerVisible(dirname)
+	if o.upperHas(dirname) {
+		infos, err := o.Upper.ReadDir(dirname)
+		if err != nil {
+			return nil, err
+		}
+		for _, info := range infos {
+			switch name := info.Name(); {
+			case name == OPAQUE_WHITEOUT:
+				lower = false
+			case strings.HasPrefix(name, WHITEOUT_PREFIX):
+				hidden[strings.TrimPrefix(name, WHITEOUT_PREFIX)] = true
+			default:
+				entries[name] = info
+			}
+		}
+	}
+	if lower {
+		infos, err := o.Lower.ReadDir(dirname)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		for _, info := range infos {
+			if _, ok := entries[info.Name()]; !ok && !hidden[info.Name()] {
+				entries[info.Name()] = info
+			}
+		}
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+	for _, info := range entries {
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	return infos, nil
+}
+
+// Glob matches against the merged view of both layers (see Glob)
+func (o *OverlayFS) Glob(pattern string) ([]string, error) {
+	return Glob(o, pattern)
+}
+
+// Rename copies oldpath up if needed and renames it in the upper layer,
+// leaving a whiteout behind if the lower layer has it
+func (o *OverlayFS) Rename(oldpath, newpath string) error {
+	if isWhiteoutName(oldpath) || isWhiteoutName(newpath) {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EINVAL}
+	}
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	info, err := o.Stat(oldpath)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() && o.lowerInfo(oldpath) != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EXDEV}
+	}
+	if existing, err := o.Stat(newpath); err == nil {
+		switch {
+		case existing.IsDir() && !info.IsDir():
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.EISDIR}
+		case !existing.IsDir() && info.IsDir():
+			return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.ENOTDIR}
+		case existing.IsDir():
+			if infos, err := o.ReadDir(newpath); err != nil {
+				return err
+			} else if len(infos) > 0 {
+				return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: syscall.ENOTEMPTY}
+			}
+		}
+	}
+	// A directory taking the place of one in the lower layer, removed or
+	// not, mustn't let its contents show through
+	opaque := false
+	if info.IsDir() {
+		opaque, _ = o.Lower.Exists(newpath)
+	}
+	if err := o.copyUp(oldpath, true); err != nil {
+		return err
+	}
+	if err := o.prepareCreate("rename", newpath); err != nil {
+		return err
+	}
+	if err := Rename(o.Upper, oldpath, newpath); err != nil {
+		return err
+	}
+	if opaque {
+		if err := o.Upper.WriteFile(path.Join(newpath, OPAQUE_WHITEOUT), nil, DEFAULT_FILE_PERM); err != nil {
+			return err
+		}
+	}
+	return o.whiteout(oldpath)
+}
+
+func (o *OverlayFS) Chmod(name string, mode os.FileMode) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if err := o.copyUp(name, true); err != nil {
+		return err
+	}
+	return Chmod(o.Upper, name, mode)
+}
+
+func (o *OverlayFS) Chtimes(name string, atime time.Time, mtime time.Time) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if err := o.copyUp(name, true); err != nil {
+		return err
+	}
+	return Chtimes(o.Upper, name, atime, mtime)
+}
+
+func (o *OverlayFS) Truncate(name string, size int64) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if err := o.copyUp(name, size > 0); err != nil {
+		return err
+	}
+	return Truncate(o.Upper, name, size)
+}
+
+// Symlink creates newname in the upper layer
+func (o *OverlayFS) Symlink(oldname, newname string) error {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	if _, err := o.Stat(newname); err == nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: os.ErrExist}
+	}
+	if err := o.prepareCreate("symlink", newname); err != nil {
+		return err
+	}
+	return Symlink(o.Upper, oldname, newname)
+}
+
+func (o *OverlayFS) Readlink(name string) (string, error) {
+	if _, err := o.Stat(name); err != nil {
+		return "", err
+	}
+	if o.upperHas(name) {
+		return Readlink(o.Upper, name)
+	}
+	return Readlink(o.Lower, name)
+}
@@ -0,0 +1,210 @@
+package easyfiles
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestOverlayFS(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	lower := NewMemFS()
+	require.Nil(lower.Makedirs("/data/2024"))
+	require.Nil(lower.WriteFile("/data/2024/a.txt", []byte("a"), 0664))
+	require.Nil(lower.WriteFile("/data/2024/b.txt", []byte("b"), 0600))
+	require.Nil(lower.WriteFile("/data/c.txt", []byte("c"), 0664))
+	upper := NewMemFS()
+	o := NewOverlayFS(lower, upper)
+
+	// Reads come from the lower layer
+	b, err := o.ReadFile("/data/2024/a.txt")
+	require.Nil(err)
+	require.Equal("a", string(b))
+	exists, err := o.Exists("/data/c.txt")
+	require.Nil(err)
+	require.True(exists)
+
+	// Appending copies the file up first, leaving the lower layer alone
+	f, err := o.Open("/data/2024/b.txt", os.O_WRONLY|os.O_APPEND, GZ_FALSE)
+	require.Nil(err)
+	_, err = f.File.Write([]byte("b"))
+	require.Nil(err)
+	require.Nil(f.Close())
+	b, err = o.ReadFile("/data/2024/b.txt")
+	require.Nil(err)
+	require.Equal("bb", string(b))
+	b, err = lower.ReadFile("/data/2024/b.txt")
+	require.Nil(err)
+	require.Equal("b", string(b))
+	info, err := upper.Stat("/data/2024/b.txt")
+	require.Nil(err)
+	require.Equal(os.FileMode(0600), info.Mode().Perm())
+
+	// New files and directories go to the upper layer
+	require.Nil(o.WriteFile("/data/2024/new.txt", []byte("new"), 0664))
+	require.Nil(o.Makedirs("/data/2025"))
+	exists, err = upper.Exists("/data/2025")
+	require.Nil(err)
+	require.True(exists)
+
+	// Removals leave whiteouts, which are never shown
+	require.Nil(o.Remove("/data/2024/a.txt"))
+	_, err = o.Stat("/data/2024/a.txt")
+	require.True(os.IsNotExist(err))
+	_, err = o.Stat("/data/2024/" + WHITEOUT_PREFIX + "a.txt")
+	require.True(os.IsNotExist(err))
+	exists, err = lower.Exists("/data/2024/a.txt")
+	require.Nil(err)
+	require.True(exists)
+	infos, err := o.ReadDir("/data/2024")
+	require.Nil(err)
+	require.Equal([]string{"b.txt", "new.txt"}, names(infos))
+	infos, err = o.ReadDir("/data")
+	require.Nil(err)
+	require.Equal([]string{"2024", "2025", "c.txt"}, names(infos))
+	matches, err := o.Glob("/data/**/*.txt")
+	require.Nil(err)
+	require.Equal([]string{"/data/2024/b.txt", "/data/2024/new.txt", "/data/c.txt"}, matches)
+	require.True(errors.Is(o.WriteFile("/data/"+WHITEOUT_PREFIX+"x", nil, 0664), syscall.EINVAL))
+
+	// Writing a removed file brings it back without the old contents
+	require.Nil(o.WriteFile("/data/2024/a.txt", []byte("A"), 0664))
+	b, err = o.ReadFile("/data/2024/a.txt")
+	require.Nil(err)
+	require.Equal("A", string(b))
+
+	// A directory recreated after removal is opaque
+	require.True(errors.Is(o.Remove("/data/2024"), syscall.ENOTEMPTY))
+	require.Nil(o.RemoveAll("/data/2024"))
+	_, err = o.Stat("/data/2024/b.txt")
+	require.True(os.IsNotExist(err))
+	require.Nil(o.Makedirs("/data/2024"))
+	infos, err = o.ReadDir("/data/2024")
+	require.Nil(err)
+	require.Empty(infos)
+	_, err = o.ReadFile("/data/2024/b.txt")
+	require.True(os.IsNotExist(err))
+
+	// Files are copied up to be renamed, lower layer directories can't be
+	require.Nil(o.Rename("/data/c.txt", "/data/2024/c.txt"))
+	b, err = o.ReadFile("/data/2024/c.txt")
+	require.Nil(err)
+	require.Equal("c", string(b))
+	_, err = o.Stat("/data/c.txt")
+	require.True(os.IsNotExist(err))
+	require.True(errors.Is(o.Rename("/data", "/moved"), syscall.EXDEV))
+
+	// A directory renamed over a removed lower layer directory hides its
+	// old contents, and can't replace one that isn't empty
+	require.Nil(lower.Makedirs("/logs"))
+	require.Nil(lower.WriteFile("/logs/old.txt", []byte("old"), 0664))
+	require.Nil(o.Makedirs("/staging"))
+	require.Nil(o.WriteFile("/staging/new.txt", []byte("new"), 0664))
+	require.True(errors.Is(o.Rename("/staging", "/logs"), syscall.ENOTEMPTY))
+	require.Nil(o.RemoveAll("/logs"))
+	require.Nil(o.Rename("/staging", "/logs"))
+	infos, err = o.ReadDir("/logs")
+	require.Nil(err)
+	require.Equal([]string{"new.txt"}, names(infos))
+	_, err = o.Stat("/staging")
+	require.True(os.IsNotExist(err))
+
+	// Truncating only copies up what's kept
+	require.Nil(lower.WriteFile("/data/d.txt", []byte("dddd"), 0664))
+	require.Nil(o.Truncate("/data/d.txt", 2))
+	b, err = o.ReadFile("/data/d.txt")
+	require.Nil(err)
+	require.Equal("dd", string(b))
+	_, err = o.Open("/data/d.txt", os.O_WRONLY|os.O_CREATE|os.O_EXCL, GZ_FALSE)
+	require.True(os.IsExist(err))
+}
+
+// pausingFS pauses opens until released, after telling opened
+type pausingFS struct {
+	FileSystemInterface
+	opened  chan struct{}
+	release chan struct{}
+}
+
+func (p *pausingFS) Open(name string, mode int, gz FileType) (*File, error) {
+	p.opened <- struct{}{}
+	<-p.release
+	return p.FileSystemInterface.Open(name, mode, gz)
+}
+
+func TestOverlayFSCopyUpReaders(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mem := NewMemFS()
+	require.Nil(mem.WriteFile("/f", []byte("data"), 0664))
+	lower := &pausingFS{mem, make(chan struct{}), make(chan struct{})}
+	o := NewOverlayFS(lower, NewMemFS())
+
+	// While the copy-up is reading the lower layer's file, readers still
+	// see the whole of it and nothing of the copy
+	errs := make(chan error)
+	go func() { errs <- o.Chmod("/f", 0600) }()
+	<-lower.opened
+	b, err := o.ReadFile("/f")
+	require.Nil(err)
+	require.Equal("data", string(b))
+	infos, err := o.ReadDir("/")
+	require.Nil(err)
+	require.Equal([]string{"f"}, names(infos))
+	close(lower.release)
+	require.Nil(<-errs)
+
+	b, err = o.ReadFile("/f")
+	require.Nil(err)
+	require.Equal("data", string(b))
+	info, err := o.Stat("/f")
+	require.Nil(err)
+	require.Equal(os.FileMode(0600), info.Mode().Perm())
+}
+
+func TestOverlayFSUpperDir(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// An upper layer kept in a directory, by way of a MountFS
+	lower := NewMemFS()
+	require.Nil(lower.WriteFile("/in.txt", []byte("in"), 0664))
+	dir := t.TempDir()
+	upper := NewMountFS()
+	require.Nil(upper.Mount("/", LocalFS, dir))
+	o := NewOverlayFS(lower, upper)
+
+	require.Nil(o.Remove("/in.txt"))
+	require.True(os.IsNotExist(o.WriteFile("/out/out.txt", nil, 0664)))
+	require.Nil(o.Makedirs("/out"))
+	require.Nil(o.WriteFile("/out/out.txt", []byte("out"), 0664))
+	f, err := OpenFile(o, "/out/deep/x.txt", WithWrite(), WithCreate(), WithParents(0775))
+	require.Nil(err)
+	require.Nil(f.Close())
+	// Parents created along the way get DirPerm in the upper layer
+	f, err = OpenFile(o, "/private/deep/x.txt", WithWrite(), WithCreate(), WithParents(0700))
+	require.Nil(err)
+	require.Nil(f.Close())
+	for _, name := range []string{"private", "private/deep"} {
+		info, err := LocalFS.Stat(filepath.Join(dir, name))
+		require.Nil(err)
+		require.Equal(os.FileMode(0700), info.Mode().Perm(), name)
+	}
+
+	b, err := LocalFS.ReadFile(filepath.Join(dir, "out", "out.txt"))
+	require.Nil(err)
+	require.Equal("out", string(b))
+	exists, err := LocalFS.Exists(filepath.Join(dir, WHITEOUT_PREFIX+"in.txt"))
+	require.Nil(err)
+	require.True(exists)
+	infos, err := o.ReadDir("/")
+	require.Nil(err)
+	require.Equal([]string{"out", "private"}, names(infos))
+}
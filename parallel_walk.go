@@ -0,0 +1,323 @@
+package easyfiles
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// ParallelWalkOptions controls WalkParallel
+type ParallelWalkOptions struct {
+	// MaxDepth, OnError and the symbolic link options work as they do for
+	// Walker. Sort makes the
+	// output deterministic: entries are passed to fn in exactly the order
+	// a sorted Walker would yield them. Without Sort, entries are passed
+	// on as soon as their directory has been listed.
+	WalkOptions
+	// Workers is the number of directories listed concurrently. A value
+	// of 0 uses 8.
+	Workers int
+}
+
+type walkListing struct {
+	dir       WalkEntry
+	ancestors *walkAncestor
+	infos     []os.FileInfo
+	err       error
+}
+
+type walkJob struct {
+	dir       WalkEntry
+	ancestors *walkAncestor
+	result    chan<- walkListing
+}
+
+// walkChild is an entry along with what it resolves to, or nil if it
+// mustn't be descended into
+type walkChild struct {
+	entry   WalkEntry
+	dirInfo os.FileInfo
+}
+
+// walkQueue hands directories out to workers. It is a stack, so that the
+// walk goes depth first and the queue stays short.
+type walkQueue struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	jobs   []walkJob
+	closed bool
+}
+
+func newWalkQueue() *walkQueue {
+	q := &walkQueue{}
+	q.cond = sync.NewCond(&q.mutex)
+	return q
+}
+
+func (q *walkQueue) push(job walkJob) {
+	q.mutex.Lock()
+	q.jobs = append(q.jobs, job)
+	q.mutex.Unlock()
+	q.cond.Signal()
+}
+
+func (q *walkQueue) pop() (walkJob, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+	for len(q.jobs) == 0 && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return walkJob{}, false
+	}
+	job := q.jobs[len(q.jobs)-1]
+	q.jobs = q.jobs[:len(q.jobs)-1]
+	return job, true
+}
+
+func (q *walkQueue) close() {
+	q.mutex.Lock()
+	q.closed = true
+	q.mutex.Unlock()
+	q.cond.Broadcast()
+}
+
+type parallelWalk struct {
+	ctx   context.Context
+	fs    FileSystemInterface
+	opts  ParallelWalkOptions
+	fn    func(WalkEntry) error
+	queue *walkQueue
+}
+
+// WalkParallel walks root like Walker, but lists up to opts.Workers
+// directories at a time. fn is called for every entry from the calling
+// goroutine, never concurrently. Returning filepath.SkipDir from fn for a
+// directory skips its contents; for a file it skips the rest of the
+// directory containing it. Any other error stops the walk and is
+// returned. Cancelling ctx stops the walk and returns ctx.Err(). opts may
+// be nil.
+func WalkParallel(ctx context.Context, fs FileSystemInterface, root string, opts *ParallelWalkOptions, fn func(WalkEntry) error) error {
+	p := &parallelWalk{fs: fs, fn: fn, queue: newWalkQueue()}
+	if opts != nil {
+		p.opts = *opts
+	}
+	if p.opts.Workers <= 0 {
+		p.opts.Workers = 8
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	p.ctx = ctx
+
+	wg := sync.WaitGroup{}
+	for idx := 0; idx < p.opts.Workers; idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.work()
+		}()
+	}
+	defer func() {
+		cancel()
+		p.queue.close()
+		wg.Wait()
+	}()
+
+	info, err := statPath(fs, root)
+	if err != nil {
+		return p.handle(root, err)
+	}
+	rootChild := walkChild{WalkEntry{root, info, 0}, info}
+	if err := fn(rootChild.entry); err != nil {
+		if err == filepath.SkipDir {
+			return nil
+		}
+		return err
+	}
+	if !p.descend(rootChild) {
+		return nil
+	}
+	if p.opts.Sort {
+		return p.walkOrdered(rootChild.entry, p.submit(rootChild, nil, make(chan walkListing, 1)))
+	}
+	return p.walkUnordered(rootChild)
+}
+
+func (p *parallelWalk) work() {
+	for {
+		job, ok := p.queue.pop()
+		if !ok {
+			return
+		}
+		infos, err := p.fs.ReadDir(job.dir.Path)
+		select {
+		case job.result <- walkListing{job.dir, job.ancestors, infos, err}:
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+func (p *parallelWalk) submit(dir walkChild, parent *walkAncestor, result chan walkListing) chan walkListing {
+	p.queue.push(walkJob{dir.entry, &walkAncestor{dir.dirInfo, parent}, result})
+	return result
+}
+
+func (c walkChild) isDir() bool {
+	return c.dirInfo != nil && c.dirInfo.IsDir()
+}
+
+func (p *parallelWalk) descend(child walkChild) bool {
+	return child.isDir() && p.opts.belowMaxDepth(child.entry.Depth)
+}
+
+// handle reports err for path and returns the error that should stop the
+// walk, if any
+func (p *parallelWalk) handle(path string, err error) error {
+	if p.opts.OnError != nil {
+		return p.opts.OnError(path, err)
+	}
+	return err
+}
+
+// children returns the entries of a listing, following links if asked
+// to. Errors following links are handled here, so the error returned is
+// the one that should stop the walk.
+func (p *parallelWalk) children(l walkListing) ([]walkChild, error) {
+	children := make([]walkChild, len(l.infos))
+	for idx, info := range l.infos {
+		entry := WalkEntry{filepath.Join(l.dir.Path, info.Name()), info, l.dir.Depth + 1}
+		dirInfo := info
+		if p.opts.FollowSymlinks && isSymlink(info) {
+			var err error
+			entry, dirInfo, err = followLink(p.fs, entry, &p.opts.WalkOptions, l.ancestors)
+			if err != nil {
+				if err := p.handle(entry.Path, err); err != nil {
+					return nil, err
+				}
+			}
+		}
+		children[idx] = walkChild{entry, dirInfo}
+	}
+	return children, nil
+}
+
+// walkUnordered passes entries on in whatever order directories finish
+// being listed
+func (p *parallelWalk) walkUnordered(root walkChild) error {
+	results := make(chan walkListing)
+	p.submit(root, nil, results)
+	outstanding := 1
+	for outstanding > 0 {
+		var l walkListing
+		select {
+		case l = <-results:
+			outstanding--
+		case <-p.ctx.Done():
+			return p.ctx.Err()
+		}
+		if l.err != nil {
+			if err := p.handle(l.dir.Path, l.err); err != nil {
+				return err
+			}
+			continue
+		}
+		children, err := p.children(l)
+		if err != nil {
+			return err
+		}
+		for _, child := range children {
+			err := p.fn(child.entry)
+			if err == filepath.SkipDir {
+				if child.isDir() {
+					continue
+				}
+				break
+			} else if err != nil {
+				return err
+			}
+			if p.descend(child) {
+				p.submit(child, l.ancestors, results)
+				outstanding++
+			}
+		}
+	}
+	return nil
+}
+
+// walkOrdered walks dir depth first in lexical order. Once a directory
+// has been listed, its first subdirectories are queued for listing, so
+// they are ready (or nearly) by the time the walk reaches them. Only
+// Workers subdirectories are listed ahead of the walk in each directory
+// it is inside of, so memory grows with Workers and the depth of the
+// tree, not with the number of directories in it.
+func (p *parallelWalk) walkOrdered(dir WalkEntry, result chan walkListing) error {
+	var l walkListing
+	select {
+	case l = <-result:
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+	if l.err != nil {
+		return p.handle(dir.Path, l.err)
+	}
+	sort.Slice(l.infos, func(i, j int) bool { return l.infos[i].Name() < l.infos[j].Name() })
+	children, err := p.children(l)
+	if err != nil {
+		return err
+	}
+
+	// The indexes of the children to descend into, and the position of
+	// each child among them
+	dirs := make([]int, 0)
+	positions := make([]int, len(children))
+	for idx, child := range children {
+		positions[idx] = -1
+		if p.descend(child) {
+			positions[idx] = len(dirs)
+			dirs = append(dirs, idx)
+		}
+	}
+	futures := make([]chan walkListing, len(children))
+	submitted := 0
+	readAhead := func(n int) {
+		if n > len(dirs) {
+			n = len(dirs)
+		}
+		// Queue in reverse so that the first subdirectory comes off the
+		// stack first
+		for pos := n - 1; pos >= submitted; pos-- {
+			idx := dirs[pos]
+			futures[idx] = p.submit(children[idx], l.ancestors, make(chan walkListing, 1))
+		}
+		if n > submitted {
+			submitted = n
+		}
+	}
+	readAhead(p.opts.Workers)
+
+	for idx, child := range children {
+		if err := p.ctx.Err(); err != nil {
+			return err
+		}
+		if pos := positions[idx]; pos >= 0 {
+			readAhead(pos + 1 + p.opts.Workers)
+		}
+		err := p.fn(child.entry)
+		if err == filepath.SkipDir {
+			if child.isDir() {
+				continue
+			}
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if futures[idx] != nil {
+			if err := p.walkOrdered(child.entry, futures[idx]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
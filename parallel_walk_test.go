@@ -0,0 +1,201 @@
+package easyfiles
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// slowReadDirFS makes ReadDir slow and records how many calls overlap
+type slowReadDirFS struct {
+	*MemFS
+	mutex   sync.Mutex
+	active  int
+	maxSeen int
+}
+
+func (s *slowReadDirFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	s.mutex.Lock()
+	s.active++
+	if s.active > s.maxSeen {
+		s.maxSeen = s.active
+	}
+	s.mutex.Unlock()
+	time.Sleep(2 * time.Millisecond)
+	defer func() {
+		s.mutex.Lock()
+		s.active--
+		s.mutex.Unlock()
+	}()
+	return s.MemFS.ReadDir(dirname)
+}
+
+func makeWideTree(require *require.Assertions, fs *MemFS) {
+	for i := 0; i < 6; i++ {
+		for j := 0; j < 4; j++ {
+			dir := fmt.Sprintf("/root/d%d/e%d", i, j)
+			require.Nil(fs.Makedirs(dir))
+			for k := 0; k < 3; k++ {
+				require.Nil(fs.WriteFile(fmt.Sprintf("%v/f%d", dir, k), nil, 0664))
+			}
+		}
+	}
+}
+
+func TestWalkParallel(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mem := NewMemFS()
+	makeWideTree(require, mem)
+	fs := &slowReadDirFS{MemFS: mem}
+
+	expected := make([]string, 0)
+	w := NewWalker(mem, "/root", &WalkOptions{Sort: true})
+	for w.Next() {
+		expected = append(expected, w.Entry().Path)
+	}
+
+	collect := func(opts *ParallelWalkOptions) []string {
+		paths := make([]string, 0)
+		err := WalkParallel(context.Background(), fs, "/root", opts, func(entry WalkEntry) error {
+			paths = append(paths, entry.Path)
+			return nil
+		})
+		require.Nil(err)
+		return paths
+	}
+
+	// Sorted output matches the sequential walker exactly
+	require.Equal(expected, collect(&ParallelWalkOptions{WalkOptions{Sort: true}, 4}))
+	require.True(fs.maxSeen > 1)
+	require.True(fs.maxSeen <= 4)
+
+	paths := collect(&ParallelWalkOptions{Workers: 4})
+	require.Equal("/root", paths[0])
+	sort.Strings(paths)
+	sort.Strings(expected)
+	require.Equal(expected, paths)
+
+	// MaxDepth and SkipDir
+	paths = collect(&ParallelWalkOptions{WalkOptions{Sort: true, MaxDepth: DepthLimit(1)}, 0})
+	require.Equal([]string{"/root", "/root/d0", "/root/d1", "/root/d2", "/root/d3", "/root/d4", "/root/d5"}, paths)
+	paths = collect(&ParallelWalkOptions{WalkOptions{Sort: true, MaxDepth: DepthLimit(0)}, 0})
+	require.Equal([]string{"/root"}, paths)
+	for _, sorted := range []bool{true, false} {
+		count := 0
+		err := WalkParallel(context.Background(), fs, "/root", &ParallelWalkOptions{WalkOptions{Sort: sorted}, 4}, func(entry WalkEntry) error {
+			count++
+			if entry.Depth == 1 {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		require.Nil(err)
+		require.Equal(7, count)
+	}
+}
+
+func TestWalkParallelStop(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mem := NewMemFS()
+	makeWideTree(require, mem)
+
+	for _, sorted := range []bool{true, false} {
+		ctx, cancel := context.WithCancel(context.Background())
+		count := 0
+		err := WalkParallel(ctx, mem, "/root", &ParallelWalkOptions{WalkOptions{Sort: sorted}, 4}, func(entry WalkEntry) error {
+			count++
+			if count == 5 {
+				cancel()
+			}
+			return nil
+		})
+		require.Equal(context.Canceled, err)
+		require.True(count < 20)
+		cancel()
+	}
+
+	// ReadDir errors go through OnError
+	fs := &failingReadDirFS{mem, "/root/d2"}
+	err := WalkParallel(context.Background(), fs, "/root", &ParallelWalkOptions{WalkOptions{Sort: true}, 2}, func(entry WalkEntry) error {
+		return nil
+	})
+	require.True(os.IsPermission(err))
+
+	failed := make([]string, 0)
+	seen := 0
+	err = WalkParallel(context.Background(), fs, "/root", &ParallelWalkOptions{WalkOptions{OnError: func(path string, err error) error {
+		failed = append(failed, path)
+		return nil
+	}}, 2}, func(entry WalkEntry) error {
+		seen++
+		return nil
+	})
+	require.Nil(err)
+	require.Equal([]string{"/root/d2"}, failed)
+	// Everything below d2 is missing
+	require.Equal(1+6+5*4*4, seen)
+
+	err = WalkParallel(context.Background(), mem, "/missing", nil, func(entry WalkEntry) error {
+		return nil
+	})
+	require.True(os.IsNotExist(err))
+}
+
+// countingReadDirFS counts directories listed
+type countingReadDirFS struct {
+	*MemFS
+	mutex  sync.Mutex
+	listed int
+}
+
+func (c *countingReadDirFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	c.mutex.Lock()
+	c.listed++
+	c.mutex.Unlock()
+	return c.MemFS.ReadDir(dirname)
+}
+
+func TestWalkParallelReadAhead(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mem := NewMemFS()
+	for idx := 0; idx < 100; idx++ {
+		require.Nil(mem.Makedirs(fmt.Sprintf("/root/d%03d", idx)))
+	}
+	fs := &countingReadDirFS{MemFS: mem}
+
+	// However long the walk takes to get to them, the directories of a
+	// wide tree are only listed a few at a time ahead of it
+	workers := 4
+	walked := 0
+	maxAhead := 0
+	err := WalkParallel(context.Background(), fs, "/root", &ParallelWalkOptions{WalkOptions{Sort: true}, workers}, func(entry WalkEntry) error {
+		if entry.Depth == 0 {
+			return nil
+		}
+		time.Sleep(time.Millisecond)
+		fs.mutex.Lock()
+		// The root's listing isn't ahead of anything
+		if ahead := fs.listed - 1 - walked; ahead > maxAhead {
+			maxAhead = ahead
+		}
+		fs.mutex.Unlock()
+		walked++
+		return nil
+	})
+	require.Nil(err)
+	require.Equal(100, walked)
+	require.True(maxAhead <= workers+1, maxAhead)
+}
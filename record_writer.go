@@ -0,0 +1,138 @@
+package easyfiles
+
+import (
+	"sync"
+)
+
+const (
+	DEFAULT_RECORD_BATCH_SIZE = 64 * 1024
+)
+
+// RecordWriter lets many goroutines write records (typically lines) to
+// one WriteLayer without interleaving them. Each record is appended to a
+// shared batch as a whole, and batches are handed to the underlying
+// writer in order once they fill up, so goroutines only contend for the
+// underlying writer once per batch rather than once per record.
+type RecordWriter struct {
+	next      WriteLayer
+	file      *File
+	batchSize int
+
+	// mutex guards the batch, writeMutex the underlying writer. When
+	// both are needed, mutex is taken first.
+	mutex      sync.Mutex
+	batch      []byte
+	closed     bool
+	writeMutex sync.Mutex
+	err        error
+}
+
+// NewRecordWriter returns a RecordWriter writing into next in batches of
+// about batchSize bytes. A batchSize of 0 uses DEFAULT_RECORD_BATCH_SIZE.
+func NewRecordWriter(next WriteLayer, batchSize int) *RecordWriter {
+	if batchSize <= 0 {
+		batchSize = DEFAULT_RECORD_BATCH_SIZE
+	}
+	return &RecordWriter{
+		next:      next,
+		batchSize: batchSize,
+		batch:     make([]byte, 0, batchSize),
+	}
+}
+
+// RecordWriter returns a RecordWriter on top of Writer(bufsize). Closing
+// the File closes the RecordWriter.
+func (f *File) RecordWriter(bufsize int, batchSize int) (*RecordWriter, error) {
+	w, err := f.Writer(bufsize)
+	if err != nil {
+		return nil, err
+	}
+	f.untrack(w)
+	r := NewRecordWriter(w, batchSize)
+	r.file = f
+	if err := f.track(r); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// WriteRecord writes p as one contiguous record
+func (r *RecordWriter) WriteRecord(p []byte) error {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return ErrWriterClosed
+	}
+	r.batch = append(r.batch, p...)
+	if len(r.batch) < r.batchSize {
+		r.mutex.Unlock()
+		return nil
+	}
+	return r.writeBatch(false)
+}
+
+// WriteLine writes line as one record, adding a trailing newline if needed
+func (r *RecordWriter) WriteLine(line string) error {
+	if len(line) == 0 || line[len(line)-1] != '\n' {
+		line += "\n"
+	}
+	return r.WriteRecord([]byte(line))
+}
+
+// Write is WriteRecord for use as an io.Writer
+func (r *RecordWriter) Write(p []byte) (int, error) {
+	if err := r.WriteRecord(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// writeBatch hands the current batch to the underlying writer. It must be
+// called with r.mutex held and releases it.
+func (r *RecordWriter) writeBatch(flush bool) error {
+	batch := r.batch
+	r.batch = make([]byte, 0, r.batchSize)
+	r.writeMutex.Lock()
+	r.mutex.Unlock()
+	defer r.writeMutex.Unlock()
+
+	if r.err != nil {
+		return r.err
+	}
+	if len(batch) > 0 {
+		_, r.err = r.next.Write(batch)
+	}
+	if r.err == nil && flush {
+		r.err = r.next.Flush()
+	}
+	return r.err
+}
+
+// Flush writes out the current batch and flushes the underlying writer
+func (r *RecordWriter) Flush() error {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return ErrWriterClosed
+	}
+	return r.writeBatch(true)
+}
+
+// Close writes out the current batch and closes the underlying writer
+func (r *RecordWriter) Close() error {
+	r.mutex.Lock()
+	if r.closed {
+		r.mutex.Unlock()
+		return nil
+	}
+	r.closed = true
+	err := r.writeBatch(false)
+	if r.file != nil {
+		r.file.untrack(r)
+	}
+	if e := r.next.Close(); err == nil {
+		err = e
+	}
+	return err
+}
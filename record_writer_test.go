@@ -0,0 +1,62 @@
+package easyfiles
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordWriter(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	numWriters := 16
+	numLines := 500
+
+	for _, fileType := range []FileType{GZ_TRUE, GZ_FALSE} {
+		fname := fmt.Sprintf("/tmp/record-writer-%v", fileType)
+		defer os.Remove(fname)
+
+		f, err := Open(fname, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, fileType)
+		require.Nil(err)
+		w, err := f.RecordWriter(64, 1024)
+		require.Nil(err)
+
+		wg := sync.WaitGroup{}
+		for writer := 0; writer < numWriters; writer++ {
+			wg.Add(1)
+			go func(writer int) {
+				defer wg.Done()
+				for line := 0; line < numLines; line++ {
+					// Lines of varying length, some longer than a batch
+					payload := strings.Repeat(fmt.Sprintf("%c", 'a'+writer), (line*37)%1500)
+					require.Nil(w.WriteLine(fmt.Sprintf("%d %d %v", writer, line, payload)))
+				}
+			}(writer)
+		}
+		wg.Wait()
+		require.Nil(f.Close())
+		require.Equal(ErrWriterClosed, w.WriteLine("late"))
+
+		f, err = Open(fname, os.O_RDONLY, fileType)
+		require.Nil(err)
+		scanner, err := f.Reader(0)
+		require.Nil(err)
+		seen := make(map[string]bool)
+		for scanner.Scan() {
+			var writer, line int
+			var payload string
+			n, _ := fmt.Sscanf(scanner.Text(), "%d %d %s", &writer, &line, &payload)
+			require.True(n >= 2, scanner.Text())
+			require.Equal(strings.Repeat(fmt.Sprintf("%c", 'a'+writer), (line*37)%1500), payload)
+			seen[fmt.Sprintf("%d %d", writer, line)] = true
+		}
+		require.Nil(scanner.Err())
+		require.Equal(numWriters*numLines, len(seen))
+		f.Close()
+	}
+}
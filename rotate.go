@@ -0,0 +1,506 @@
+package easyfiles
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotateOptions controls when a RotatingWriter rotates and which rotated
+// files it keeps around. Zero values disable the corresponding feature.
+type RotateOptions struct {
+	// Interval rotates the file whenever the wall clock enters a new
+	// interval. Intervals are aligned to the local clock, so an interval
+	// of 24h rotates at local midnight.
+	Interval time.Duration
+	// MaxSize rotates the file once writing would push it past MaxSize bytes.
+	MaxSize int64
+	// Compress gzips rotated files in the background and removes the
+	// uncompressed original.
+	Compress bool
+	// MaxAge removes rotated files whose modification time is older than MaxAge.
+	MaxAge time.Duration
+	// MaxCount keeps at most MaxCount rotated files.
+	MaxCount int
+	// MaxBytes keeps the total size of rotated files under MaxBytes.
+	MaxBytes int64
+	// BufSize is passed on to File.Writer
+	BufSize int
+	// Clock overrides time.Now. Mostly useful for tests.
+	Clock func() time.Time
+}
+
+// RotatingWriter is an io.WriteCloser that writes to a file named after a
+// strftime-style pattern and moves on to a new file when the configured
+// interval elapses or the file grows too large. It is safe to use from
+// multiple goroutines; every call to Write lands in a single file.
+type RotatingWriter struct {
+	fs      FileSystemInterface
+	pattern string
+	opts    RotateOptions
+
+	mutex       sync.Mutex
+	file        *File
+	writer      *Writer
+	name        string
+	size        int64
+	periodStart time.Time
+	seq         int
+	closed      bool
+
+	// Background compression and pruning. pendingMutex guards pending
+	// and active, which the background goroutine needs to stay clear of.
+	bgMutex      sync.Mutex
+	wg           sync.WaitGroup
+	bgErr        error
+	pendingMutex sync.Mutex
+	pending      map[string]bool
+	active       string
+}
+
+// NewRotatingWriter opens (or appends to) the file for the current period
+// and returns a writer that rotates it according to opts.
+func NewRotatingWriter(fs FileSystemInterface, pattern string, opts RotateOptions) (*RotatingWriter, error) {
+	if pattern == "" {
+		return nil, errors.New("Rotating writer needs a file name pattern")
+	}
+	if opts.Clock == nil {
+		opts.Clock = time.Now
+	}
+	w := &RotatingWriter{
+		fs:      fs,
+		pattern: pattern,
+		opts:    opts,
+		pending: make(map[string]bool),
+	}
+	if err := w.openPeriod(opts.Clock()); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+// Name returns the name of the file currently being written to
+func (w *RotatingWriter) Name() string {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return w.name
+}
+
+func (w *RotatingWriter) Write(p []byte) (n int, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return 0, os.ErrClosed
+	}
+
+	now := w.opts.Clock()
+	if w.opts.Interval > 0 && !now.Before(w.periodStart.Add(w.opts.Interval)) {
+		if err = w.rotate(); err != nil {
+			return
+		}
+		if err = w.openPeriod(now); err != nil {
+			return
+		}
+	} else if w.opts.MaxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.opts.MaxSize {
+		if err = w.rotate(); err != nil {
+			return
+		}
+		if err = w.openNext(); err != nil {
+			return
+		}
+	}
+
+	n, err = w.writer.Write(p)
+	w.size += int64(n)
+	return
+}
+
+// Flush flushes buffered data to the current file
+func (w *RotatingWriter) Flush() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	return w.writer.Flush()
+}
+
+// Rotate forces a rotation regardless of interval and size
+func (w *RotatingWriter) Rotate() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.closed {
+		return os.ErrClosed
+	}
+	if err := w.rotate(); err != nil {
+		return err
+	}
+	now := w.opts.Clock()
+	if w.opts.Interval > 0 && !now.Before(w.periodStart.Add(w.opts.Interval)) {
+		return w.openPeriod(now)
+	}
+	return w.openNext()
+}
+
+// Close closes the current file and waits for any background compression
+// and pruning to finish. It returns the first error encountered in the
+// background, if any.
+func (w *RotatingWriter) Close() error {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil
+	}
+	w.closed = true
+	err := w.closeCurrent()
+	w.mutex.Unlock()
+
+	w.wg.Wait()
+	if err != nil {
+		return err
+	}
+	w.bgMutex.Lock()
+	defer w.bgMutex.Unlock()
+	return w.bgErr
+}
+
+func (w *RotatingWriter) periodFor(t time.Time) time.Time {
+	if w.opts.Interval <= 0 {
+		return t
+	}
+	_, offset := t.Zone()
+	shift := time.Duration(offset) * time.Second
+	return t.Add(shift).Truncate(w.opts.Interval).Add(-shift)
+}
+
+func (w *RotatingWriter) seqName(base string, seq int) string {
+	if seq == 0 {
+		return base
+	}
+	return fmt.Sprintf("%v.%d", base, seq)
+}
+
+func (w *RotatingWriter) exists(name string) (bool, error) {
+	if exists, err := w.fs.Exists(name); err != nil || exists {
+		return exists, err
+	}
+	if w.opts.Compress {
+		return w.fs.Exists(name + ".gz")
+	}
+	return false, nil
+}
+
+// openPeriod opens the first file of the period that t falls in. If a
+// file for this period already exists (say, after a restart), it is
+// appended to.
+func (w *RotatingWriter) openPeriod(t time.Time) error {
+	w.periodStart = w.periodFor(t)
+	w.seq = 0
+	base := strftime(w.pattern, w.periodStart)
+	// Skip past files that were already rotated away in this period
+	for {
+		name := w.seqName(base, w.seq)
+		if w.isPending(name) {
+			w.seq++
+			continue
+		}
+		if gz, err := w.fs.Exists(name + ".gz"); err != nil {
+			return err
+		} else if gz && w.opts.Compress {
+			w.seq++
+			continue
+		}
+		break
+	}
+	return w.open(w.seqName(base, w.seq))
+}
+
+// openNext opens the next unused sequence number in the current period
+func (w *RotatingWriter) openNext() error {
+	base := strftime(w.pattern, w.periodStart)
+	for {
+		w.seq++
+		name := w.seqName(base, w.seq)
+		exists, err := w.exists(name)
+		if err != nil {
+			return err
+		}
+		if !exists && !w.isPending(name) {
+			return w.open(name)
+		}
+	}
+}
+
+func (w *RotatingWriter) open(name string) error {
+	f, err := w.fs.Open(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, GZ_FALSE)
+	if err != nil {
+		return err
+	}
+	writer, err := f.Writer(w.opts.BufSize)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.size = 0
+	if info, err := w.fs.Stat(name); err == nil && info != nil {
+		w.size = info.Size()
+	}
+	w.file = f
+	w.writer = writer
+	w.name = name
+	w.pendingMutex.Lock()
+	w.active = name
+	w.pendingMutex.Unlock()
+	return nil
+}
+
+func (w *RotatingWriter) isPending(name string) bool {
+	w.pendingMutex.Lock()
+	defer w.pendingMutex.Unlock()
+	return w.pending[name]
+}
+
+func (w *RotatingWriter) closeCurrent() error {
+	if w.file == nil {
+		return nil
+	}
+	// Closing the file closes its writer
+	err := w.file.Close()
+	w.file = nil
+	w.writer = nil
+	return err
+}
+
+// rotate closes the current file and hands it over to the background
+// goroutine for compression and pruning
+func (w *RotatingWriter) rotate() error {
+	name := w.name
+	if err := w.closeCurrent(); err != nil {
+		return err
+	}
+	if !w.opts.Compress && w.opts.MaxAge <= 0 && w.opts.MaxCount <= 0 && w.opts.MaxBytes <= 0 {
+		return nil
+	}
+	w.pendingMutex.Lock()
+	w.pending[name] = true
+	w.pendingMutex.Unlock()
+
+	w.wg.Add(1)
+	go func() {
+		defer w.wg.Done()
+		w.bgMutex.Lock()
+		defer w.bgMutex.Unlock()
+
+		var err error
+		if w.opts.Compress {
+			err = w.compress(name)
+		}
+		w.pendingMutex.Lock()
+		delete(w.pending, name)
+		w.pendingMutex.Unlock()
+		if err == nil {
+			err = w.prune()
+		}
+		if err != nil && w.bgErr == nil {
+			w.bgErr = err
+		}
+	}()
+	return nil
+}
+
+func (w *RotatingWriter) compress(name string) (err error) {
+	src, err := w.fs.Open(name, os.O_RDONLY, GZ_FALSE)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := w.fs.Open(name+".gz", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, GZ_TRUE)
+	if err != nil {
+		return err
+	}
+	writer, err := dst.Writer(w.opts.BufSize)
+	if err != nil {
+		dst.Close()
+		return err
+	}
+	if _, err = io.Copy(writer, src.File); err != nil {
+		dst.Close()
+		w.fs.Remove(name + ".gz")
+		return err
+	}
+	if err = dst.Close(); err != nil {
+		return err
+	}
+	return w.fs.Remove(name)
+}
+
+type rotatedFile struct {
+	name string
+	info os.FileInfo
+}
+
+// prune enforces MaxAge, MaxCount and MaxBytes on the rotated files.
+// The file currently being written to and files still waiting for
+// compression are never removed.
+func (w *RotatingWriter) prune() error {
+	if w.opts.MaxAge <= 0 && w.opts.MaxCount <= 0 && w.opts.MaxBytes <= 0 {
+		return nil
+	}
+	base, err := CompileGlob(strftimeGlob(w.pattern), false)
+	if err != nil {
+		return err
+	}
+	matches, err := w.fs.Glob(strftimeGlob(w.pattern) + "*")
+	if err != nil {
+		return err
+	}
+
+	rotated := make([]rotatedFile, 0, len(matches))
+	for _, name := range matches {
+		// Other files that merely start with the same name aren't ours
+		if !isRotatedName(base, name) {
+			continue
+		}
+		w.pendingMutex.Lock()
+		skip := name == w.active || w.pending[name]
+		w.pendingMutex.Unlock()
+		if skip {
+			continue
+		}
+		info, err := w.fs.Stat(name)
+		if err != nil || info == nil || info.IsDir() {
+			continue
+		}
+		rotated = append(rotated, rotatedFile{name, info})
+	}
+	// Newest first
+	sort.Slice(rotated, func(i, j int) bool {
+		return rotated[i].info.ModTime().After(rotated[j].info.ModTime())
+	})
+
+	now := w.opts.Clock()
+	total := int64(0)
+	for idx, r := range rotated {
+		total += r.info.Size()
+		remove := false
+		if w.opts.MaxCount > 0 && idx >= w.opts.MaxCount {
+			remove = true
+		}
+		if w.opts.MaxAge > 0 && now.Sub(r.info.ModTime()) > w.opts.MaxAge {
+			remove = true
+		}
+		if w.opts.MaxBytes > 0 && total > w.opts.MaxBytes {
+			remove = true
+		}
+		if remove {
+			if err := w.fs.Remove(r.name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// strftime formats t according to a subset of the C strftime conversions
+func strftime(pattern string, t time.Time) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c != '%' || i == len(pattern)-1 {
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		switch pattern[i] {
+		case 'Y':
+			fmt.Fprintf(&b, "%04d", t.Year())
+		case 'y':
+			fmt.Fprintf(&b, "%02d", t.Year()%100)
+		case 'm':
+			fmt.Fprintf(&b, "%02d", int(t.Month()))
+		case 'd':
+			fmt.Fprintf(&b, "%02d", t.Day())
+		case 'H':
+			fmt.Fprintf(&b, "%02d", t.Hour())
+		case 'M':
+			fmt.Fprintf(&b, "%02d", t.Minute())
+		case 'S':
+			fmt.Fprintf(&b, "%02d", t.Second())
+		case 'j':
+			fmt.Fprintf(&b, "%03d", t.YearDay())
+		case 'b':
+			b.WriteString(t.Format("Jan"))
+		case 'B':
+			b.WriteString(t.Format("January"))
+		case 'a':
+			b.WriteString(t.Format("Mon"))
+		case 'A':
+			b.WriteString(t.Format("Monday"))
+		case 'p':
+			b.WriteString(t.Format("PM"))
+		case 'Z':
+			b.WriteString(t.Format("MST"))
+		case 'z':
+			b.WriteString(t.Format("-0700"))
+		case 's':
+			b.WriteString(strconv.FormatInt(t.Unix(), 10))
+		case 'F':
+			b.WriteString(t.Format("2006-01-02"))
+		case 'T':
+			b.WriteString(t.Format("15:04:05"))
+		case '%':
+			b.WriteByte('%')
+		default:
+			b.WriteByte('%')
+			b.WriteByte(pattern[i])
+		}
+	}
+	return b.String()
+}
+
+var rotatedSeqRegex = regexp.MustCompile(`^(.+)\.\d+$`)
+
+// isRotatedName reports whether name is one a writer whose pattern
+// compiles to base could have produced: a name for some period, followed
+// by an optional sequence number and .gz
+func isRotatedName(base *GlobPattern, name string) bool {
+	name = strings.TrimSuffix(name, ".gz")
+	if base.Match(name) {
+		return true
+	}
+	m := rotatedSeqRegex.FindStringSubmatch(name)
+	return m != nil && base.Match(m[1])
+}
+
+// strftimeGlob turns a strftime pattern into a glob that matches every
+// name the pattern can produce
+func strftimeGlob(pattern string) string {
+	var b strings.Builder
+	for i := 0; i < len(pattern); i++ {
+		c := pattern[i]
+		if c == '%' && i < len(pattern)-1 {
+			i++
+			if pattern[i] == '%' {
+				b.WriteByte('%')
+			} else if !strings.HasSuffix(b.String(), "*") {
+				b.WriteByte('*')
+			}
+			continue
+		}
+		switch c {
+		case '*', '?', '[', ']', '{', '}', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,175 @@
+package easyfiles
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func TestStrftime(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	ts := time.Date(2017, time.March, 4, 5, 6, 7, 0, time.UTC)
+	require.Equal("2017-03-04 05:06:07", strftime("%Y-%m-%d %H:%M:%S", ts))
+	require.Equal("log.17063.Mar%", strftime("log.%y%j.%b%%", ts))
+	require.Equal("log.*.txt", strftimeGlob("log.%Y%m%d.txt"))
+}
+
+func TestRotatingWriterSize(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "rotate-size")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	w, err := NewRotatingWriter(LocalFS, filepath.Join(dir, "out.log"), RotateOptions{MaxSize: 10})
+	require.Nil(err)
+	for i := 0; i < 5; i++ {
+		_, err = w.Write([]byte("abcdefgh\n"))
+		require.Nil(err)
+	}
+	require.Nil(w.Close())
+
+	files, err := ListFiles(LocalFS, dir, []string{"out.log*"})
+	require.Nil(err)
+	require.Equal(5, len(files))
+	for _, f := range files {
+		b, err := ioutil.ReadFile(f)
+		require.Nil(err)
+		require.Equal("abcdefgh\n", string(b))
+	}
+}
+
+func TestRotatingWriterInterval(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "rotate-interval")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	clock := &fakeClock{now: time.Date(2017, time.March, 4, 5, 0, 0, 0, time.Local)}
+	opts := RotateOptions{
+		Interval: time.Hour,
+		Compress: true,
+		Clock:    clock.Now,
+	}
+	w, err := NewRotatingWriter(LocalFS, filepath.Join(dir, "out.%Y%m%d%H.log"), opts)
+	require.Nil(err)
+
+	for i := 0; i < 3; i++ {
+		_, err = w.Write([]byte(fmt.Sprintf("line %d\n", i)))
+		require.Nil(err)
+		clock.Advance(time.Hour)
+	}
+	require.Nil(w.Close())
+
+	files, err := ListFiles(LocalFS, dir, []string{"*"})
+	require.Nil(err)
+	sort.Strings(files)
+	expected := []string{
+		filepath.Join(dir, "out.2017030405.log.gz"),
+		filepath.Join(dir, "out.2017030406.log.gz"),
+		filepath.Join(dir, "out.2017030407.log"),
+	}
+	require.Equal(expected, files)
+
+	f, err := Open(expected[1], os.O_RDONLY, GZ_UNKNOWN)
+	require.Nil(err)
+	defer f.Close()
+	buf := bytes.NewBuffer(nil)
+	reader, err := f.RawReader()
+	require.Nil(err)
+	_, err = buf.ReadFrom(reader)
+	require.Nil(err)
+	require.Equal("line 1\n", buf.String())
+}
+
+func TestRotatingWriterRetention(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "rotate-retention")
+	require.Nil(err)
+	defer os.RemoveAll(dir)
+
+	opts := RotateOptions{
+		MaxSize:  4,
+		Compress: true,
+		MaxCount: 2,
+	}
+	// Files that only start with the same name are left alone
+	for _, name := range []string{"out.log.bak", "out.log-old", "out.log.1.bak"} {
+		require.Nil(LocalFS.WriteFile(filepath.Join(dir, name), nil, 0664))
+	}
+	w, err := NewRotatingWriter(LocalFS, filepath.Join(dir, "out.log"), opts)
+	require.Nil(err)
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := w.Write([]byte("abc\n"))
+			require.Nil(err)
+		}()
+	}
+	wg.Wait()
+	require.Nil(w.Close())
+
+	files, err := ListFiles(LocalFS, dir, []string{"*.gz"})
+	require.Nil(err)
+	require.Equal(2, len(files))
+	// The active file is never pruned
+	require.True(Exists(w.Name()))
+	for _, name := range []string{"out.log.bak", "out.log-old", "out.log.1.bak"} {
+		require.True(Exists(filepath.Join(dir, name)), name)
+	}
+}
+
+func TestIsRotatedName(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	base, err := CompileGlob(strftimeGlob("/logs/app-%Y%m%d.log"), false)
+	require.Nil(err)
+	for name, expected := range map[string]bool{
+		"/logs/app-20240101.log":        true,
+		"/logs/app-20240101.log.3":      true,
+		"/logs/app-20240101.log.3.gz":   true,
+		"/logs/app-20240101.log.gz":     true,
+		"/logs/app-20240101.log.bak":    false,
+		"/logs/app-20240101.log-old":    false,
+		"/logs/app-20240101.log.3.bak":  false,
+		"/logs/app-20240101.log.gz.old": false,
+	} {
+		require.Equal(expected, isRotatedName(base, name), name)
+	}
+}
@@ -0,0 +1,231 @@
+package easyfiles
+
+import (
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ListEntry is a path along with its FileInfo
+type ListEntry struct {
+	Path string
+	Info os.FileInfo
+}
+
+// ListOrder reports whether a sorts before b
+type ListOrder func(a, b ListEntry) bool
+
+var (
+	// LexicalOrder sorts by path, byte by byte
+	LexicalOrder ListOrder = func(a, b ListEntry) bool {
+		return a.Path < b.Path
+	}
+	// NaturalOrder sorts by path, comparing runs of digits by their
+	// numeric value, so a.txt.2 sorts before a.txt.10 and v1.9 before
+	// v1.10
+	NaturalOrder ListOrder = func(a, b ListEntry) bool {
+		return NaturalLess(a.Path, b.Path)
+	}
+	// ModTimeOrder sorts oldest first
+	ModTimeOrder ListOrder = func(a, b ListEntry) bool {
+		return a.Info.ModTime().Before(b.Info.ModTime())
+	}
+	// SizeOrder sorts smallest first
+	SizeOrder ListOrder = func(a, b ListEntry) bool {
+		return a.Info.Size() < b.Info.Size()
+	}
+)
+
+// ListOptions controls listings and the order of their results
+type ListOptions struct {
+	// Order defaults to LexicalOrder. Entries it considers equal are
+	// left in NaturalOrder.
+	Order   ListOrder
+	Reverse bool
+	// FollowSymlinks lists the contents of linked directories and treats
+	// links as what they point to (see WalkOptions)
+	FollowSymlinks bool
+	// OnError decides what happens to paths that can't be listed, as it
+	// does for a Walker. If nil, the listing fails with the first error.
+	OnError WalkErrorHandler
+}
+
+type ListOption func(*ListOptions)
+
+// WithOrder sorts results with order, which may be one of the orders
+// above or any other comparator
+func WithOrder(order ListOrder) ListOption {
+	return func(o *ListOptions) { o.Order = order }
+}
+
+// WithReverse reverses the order of the results
+func WithReverse() ListOption {
+	return func(o *ListOptions) { o.Reverse = true }
+}
+
+// WithFollowSymlinks descends into symbolic links to directories
+func WithFollowSymlinks() ListOption {
+	return func(o *ListOptions) { o.FollowSymlinks = true }
+}
+
+// WithOnError has the errors of paths that can't be listed passed to
+// handler, which may skip them, collect them or stop the listing
+func WithOnError(handler WalkErrorHandler) ListOption {
+	return func(o *ListOptions) { o.OnError = handler }
+}
+
+func newListOptions(opts []ListOption) *ListOptions {
+	o := &ListOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.Order == nil {
+		o.Order = LexicalOrder
+	}
+	return o
+}
+
+func (o *ListOptions) onError(path string, err error) error {
+	if o.OnError != nil {
+		return o.OnError(path, err)
+	}
+	return err
+}
+
+func (o *ListOptions) sort(entries []ListEntry) {
+	sort.Slice(entries, func(i, j int) bool { return NaturalLess(entries[i].Path, entries[j].Path) })
+	less := o.Order
+	if o.Reverse {
+		less = func(a, b ListEntry) bool { return o.Order(b, a) }
+	}
+	sort.SliceStable(entries, func(i, j int) bool { return less(entries[i], entries[j]) })
+}
+
+// SortEntries sorts entries according to opts
+func SortEntries(entries []ListEntry, opts ...ListOption) {
+	newListOptions(opts).sort(entries)
+}
+
+// SortPaths sorts paths on fs according to opts, such as the results of
+// Glob. Every path is stat'd.
+func SortPaths(fs FileSystemInterface, paths []string, opts ...ListOption) error {
+	entries := make([]ListEntry, len(paths))
+	for idx, p := range paths {
+		info, err := statPath(fs, p)
+		if err != nil {
+			return err
+		}
+		entries[idx] = ListEntry{p, info}
+	}
+	newListOptions(opts).sort(entries)
+	for idx := range entries {
+		paths[idx] = entries[idx].Path
+	}
+	return nil
+}
+
+func isDigit(c byte) bool {
+	return '0' <= c && c <= '9'
+}
+
+// NaturalLess compares a and b byte by byte, except that runs of digits
+// are compared by their numeric value. Numbers that differ only in their
+// leading zeros are only told apart if the names are otherwise equal,
+// fewer zeros first.
+func NaturalLess(a, b string) bool {
+	i, j := 0, 0
+	zeros := 0
+	for i < len(a) && j < len(b) {
+		if !isDigit(a[i]) || !isDigit(b[j]) {
+			if a[i] != b[j] {
+				return a[i] < b[j]
+			}
+			i++
+			j++
+			continue
+		}
+		si, sj := i, j
+		for i < len(a) && isDigit(a[i]) {
+			i++
+		}
+		for j < len(b) && isDigit(b[j]) {
+			j++
+		}
+		na, nb := strings.TrimLeft(a[si:i], "0"), strings.TrimLeft(b[sj:j], "0")
+		switch {
+		case len(na) != len(nb):
+			return len(na) < len(nb)
+		case na != nb:
+			return na < nb
+		case zeros == 0:
+			zeros = (i - si) - (j - sj)
+		}
+	}
+	if len(a)-i != len(b)-j {
+		return len(a)-i < len(b)-j
+	}
+	return zeros < 0
+}
+
+// Shard is one of the numbered files of a ShardGroup
+type Shard struct {
+	Path  string
+	Index int
+}
+
+// ShardGroup is a set of numbered files sharing a base name, such as
+// those written by RotatingWriter: x.log, x.log.1, x.log.2.gz, ..
+type ShardGroup struct {
+	Base   string
+	Shards []Shard
+}
+
+var shardRegex = regexp.MustCompile(`^(.+)[._-](\d+)$`)
+
+// shardOf splits p into its base name and shard number. A trailing .gz
+// is ignored, and a file without a number is shard 0 of its own name.
+func shardOf(p string) (string, int) {
+	trimmed := strings.TrimSuffix(p, ".gz")
+	if m := shardRegex.FindStringSubmatch(trimmed); m != nil {
+		if idx, err := strconv.Atoi(m[2]); err == nil {
+			return m[1], idx
+		}
+	}
+	return trimmed, 0
+}
+
+// GroupShards groups paths whose names are a common base name followed
+// by a ., - or _ and a number, optionally compressed: x.log, x.log.1 and
+// x.log.2.gz are shards 0, 1 and 2 of x.log, and part-00003 is shard 3
+// of part. Groups are in natural order of their base names and the
+// shards of each group in order of their numbers.
+func GroupShards(paths []string) []ShardGroup {
+	groups := make(map[string]*ShardGroup)
+	bases := make([]string, 0)
+	for _, p := range paths {
+		base, idx := shardOf(p)
+		g, ok := groups[base]
+		if !ok {
+			g = &ShardGroup{Base: base}
+			groups[base] = g
+			bases = append(bases, base)
+		}
+		g.Shards = append(g.Shards, Shard{p, idx})
+	}
+	sort.Slice(bases, func(i, j int) bool { return NaturalLess(bases[i], bases[j]) })
+
+	ret := make([]ShardGroup, len(bases))
+	for idx, base := range bases {
+		g := groups[base]
+		sort.Slice(g.Shards, func(i, j int) bool {
+			if g.Shards[i].Index != g.Shards[j].Index {
+				return g.Shards[i].Index < g.Shards[j].Index
+			}
+			return NaturalLess(g.Shards[i].Path, g.Shards[j].Path)
+		})
+		ret[idx] = *g
+	}
+	return ret
+}
@@ -0,0 +1,91 @@
+package easyfiles
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNaturalLess(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	names := []string{
+		"a.txt.10", "a.txt", "a.txt.2", "b", "a.txt.1", "v1.10", "v1.9", "v1.9.1",
+		"part-010", "part-10", "part-9", "x01y", "x1y", "x1z",
+	}
+	sort.Slice(names, func(i, j int) bool { return NaturalLess(names[i], names[j]) })
+	require.Equal([]string{
+		"a.txt", "a.txt.1", "a.txt.2", "a.txt.10", "b", "part-9", "part-10", "part-010",
+		"v1.9", "v1.9.1", "v1.10", "x1y", "x01y", "x1z",
+	}, names)
+
+	require.False(NaturalLess("a1", "a1"))
+	require.True(NaturalLess("", "a"))
+	require.False(NaturalLess("a", ""))
+}
+
+func TestListOrders(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	fs := NewMemFS()
+	require.Nil(fs.Makedirs("/root"))
+	now := time.Now()
+	for idx, name := range []string{"a.txt.10", "a.txt.2", "a.txt.1", "a.txt"} {
+		p := filepath.Join("/root", name)
+		require.Nil(fs.WriteFile(p, []byte(strings.Repeat("x", idx)), 0664))
+		mtime := now.Add(time.Duration(idx) * time.Minute)
+		require.Nil(fs.Chtimes(p, mtime, mtime))
+	}
+
+	list := func(opts ...ListOption) []string {
+		files, err := ListFiles(fs, "/root", []string{"a.*"}, opts...)
+		require.Nil(err)
+		for idx := range files {
+			files[idx] = filepath.Base(files[idx])
+		}
+		return files
+	}
+	require.Equal([]string{"a.txt", "a.txt.1", "a.txt.10", "a.txt.2"}, list())
+	require.Equal([]string{"a.txt", "a.txt.1", "a.txt.2", "a.txt.10"}, list(WithOrder(NaturalOrder)))
+	require.Equal([]string{"a.txt.10", "a.txt.2", "a.txt.1", "a.txt"}, list(WithOrder(ModTimeOrder)))
+	require.Equal([]string{"a.txt", "a.txt.1", "a.txt.2", "a.txt.10"}, list(WithOrder(SizeOrder), WithReverse()))
+	byLength := func(a, b ListEntry) bool { return len(a.Path) < len(b.Path) }
+	// Ties stay in natural order
+	require.Equal([]string{"a.txt", "a.txt.1", "a.txt.2", "a.txt.10"}, list(WithOrder(byLength)))
+
+	require.Nil(fs.Makedirs("/root/d10"))
+	require.Nil(fs.Makedirs("/root/d9"))
+	dirs, err := ListDirs(fs, "/root", []string{"*"}, WithOrder(NaturalOrder))
+	require.Nil(err)
+	require.Equal([]string{"/root/d9", "/root/d10"}, dirs)
+
+	paths, err := Glob(fs, "/root/a.*")
+	require.Nil(err)
+	require.Nil(SortPaths(fs, paths, WithOrder(SizeOrder)))
+	require.Equal([]string{"/root/a.txt.10", "/root/a.txt.2", "/root/a.txt.1", "/root/a.txt"}, paths)
+	require.NotNil(SortPaths(fs, []string{"/root/missing"}, WithOrder(SizeOrder)))
+}
+
+func TestGroupShards(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	groups := GroupShards([]string{
+		"/logs/x.log.10", "/logs/x.log.2.gz", "/logs/x.log", "/logs/x.log.1",
+		"/data/part-00003", "/data/part-00001", "/logs/y.log", "/logs/x.log.2",
+	})
+	require.Equal([]ShardGroup{
+		{"/data/part", []Shard{{"/data/part-00001", 1}, {"/data/part-00003", 3}}},
+		{"/logs/x.log", []Shard{
+			{"/logs/x.log", 0}, {"/logs/x.log.1", 1}, {"/logs/x.log.2", 2}, {"/logs/x.log.2.gz", 2}, {"/logs/x.log.10", 10},
+		}},
+		{"/logs/y.log", []Shard{{"/logs/y.log", 0}}},
+	}, groups)
+	require.Equal([]ShardGroup{}, GroupShards(nil))
+}
@@ -0,0 +1,72 @@
+package easyfiles
+
+import (
+	"fmt"
+	"os"
+)
+
+// DanglingLinkError is reported for symbolic links whose target doesn't
+// exist when a walk follows links
+type DanglingLinkError struct {
+	Path   string
+	Target string
+}
+
+func (e *DanglingLinkError) Error() string {
+	return fmt.Sprintf("Dangling symbolic link: %v -> %v", e.Path, e.Target)
+}
+
+// SymlinkLoopError is reported for symbolic links that point back at a
+// directory the walk is already inside of
+type SymlinkLoopError struct {
+	Path   string
+	Target string
+}
+
+func (e *SymlinkLoopError) Error() string {
+	return fmt.Sprintf("Symbolic link loop: %v -> %v", e.Path, e.Target)
+}
+
+// walkAncestor is a directory the walk is inside of, linked to its parent
+type walkAncestor struct {
+	info   os.FileInfo
+	parent *walkAncestor
+}
+
+// contains reports whether info is one of the directories, compared by
+// device and inode
+func (a *walkAncestor) contains(info os.FileInfo) bool {
+	for ; a != nil; a = a.parent {
+		if os.SameFile(a.info, info) {
+			return true
+		}
+	}
+	return false
+}
+
+func isSymlink(info os.FileInfo) bool {
+	return info.Mode()&os.ModeSymlink != 0
+}
+
+// followLink resolves entry, a symbolic link found by a walk that follows
+// links. It returns the entry to report and the FileInfo of its target if
+// the walk should descend into it. Dangling links and loops are returned
+// as errors; they are still reported, but never descended into.
+func followLink(fs FileSystemInterface, entry WalkEntry, opts *WalkOptions, ancestors *walkAncestor) (WalkEntry, os.FileInfo, error) {
+	target, err := statPath(fs, entry.Path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			dest, _ := Readlink(fs, entry.Path)
+			err = &DanglingLinkError{entry.Path, dest}
+		}
+		return entry, nil, err
+	}
+	if opts.ReportLinkTargets {
+		entry.Info = target
+	}
+	if target.IsDir() && ancestors.contains(target) {
+		dest, _ := Readlink(fs, entry.Path)
+		return entry, nil, &SymlinkLoopError{entry.Path, dest}
+	}
+	return entry, target, nil
+}
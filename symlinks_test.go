@@ -0,0 +1,153 @@
+package easyfiles
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// makeLinkTree adds links to makeWalkTree's tree: one to a directory, one
+// back up to the root and one to nothing
+func makeLinkTree(require *require.Assertions, root string) {
+	makeWalkTree(require, LocalFS, root)
+	require.Nil(Symlink(LocalFS, filepath.Join(root, "a", "1"), filepath.Join(root, "c", "one")))
+	require.Nil(Symlink(LocalFS, "..", filepath.Join(root, "b", "up")))
+	require.Nil(Symlink(LocalFS, filepath.Join(root, "missing"), filepath.Join(root, "dangling")))
+}
+
+func TestWalkerFollowSymlinks(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir := t.TempDir()
+	makeLinkTree(require, dir)
+
+	walk := func(opts *WalkOptions) ([]string, []error) {
+		errs := make([]error, 0)
+		opts.Sort = true
+		opts.OnError = func(path string, err error) error {
+			errs = append(errs, err)
+			return nil
+		}
+		paths := make([]string, 0)
+		w := NewWalker(LocalFS, dir, opts)
+		for w.Next() {
+			rel, _ := filepath.Rel(dir, w.Entry().Path)
+			paths = append(paths, rel)
+		}
+		require.Nil(w.Err())
+		return paths, errs
+	}
+
+	// Not following, links are just entries
+	paths, errs := walk(&WalkOptions{})
+	require.Equal([]string{".", "a", "a/1", "a/1/y.txt", "a/2", "a/2/z.gz", "a.txt", "b", "b/up", "b/x.txt", "c", "c/one", "dangling"}, paths)
+	require.Empty(errs)
+
+	paths, errs = walk(&WalkOptions{FollowSymlinks: true})
+	require.Equal([]string{".", "a", "a/1", "a/1/y.txt", "a/2", "a/2/z.gz", "a.txt", "b", "b/up", "b/x.txt", "c", "c/one", "c/one/y.txt", "dangling"}, paths)
+	require.Equal(2, len(errs))
+	loop := &SymlinkLoopError{}
+	require.True(errors.As(errs[0], &loop))
+	require.Equal(filepath.Join(dir, "b", "up"), loop.Path)
+	require.Equal("..", loop.Target)
+	dangling := &DanglingLinkError{}
+	require.True(errors.As(errs[1], &dangling))
+	require.Equal(filepath.Join(dir, "missing"), dangling.Target)
+
+	// Links are reported as links unless asked otherwise
+	for _, reportTargets := range []bool{false, true} {
+		w := NewWalker(LocalFS, filepath.Join(dir, "c"), &WalkOptions{FollowSymlinks: true, ReportLinkTargets: reportTargets})
+		require.True(w.Next())
+		require.True(w.Next())
+		require.Equal("one", w.Entry().Info.Name())
+		require.Equal(reportTargets, w.Entry().Info.IsDir())
+		require.Equal(!reportTargets, isSymlink(w.Entry().Info))
+		require.True(w.Next())
+		require.Equal(filepath.Join(dir, "c", "one", "y.txt"), w.Entry().Path)
+		require.False(w.Next())
+		require.Nil(w.Err())
+	}
+
+	// Without OnError, the first bad link stops the walk
+	w := NewWalker(LocalFS, dir, &WalkOptions{Sort: true, FollowSymlinks: true})
+	for w.Next() {
+	}
+	require.True(errors.As(w.Err(), &loop))
+
+	// A loop further down than its target's parent
+	require.Nil(Symlink(LocalFS, dir, filepath.Join(dir, "a", "1", "root")))
+	_, errs = walk(&WalkOptions{FollowSymlinks: true})
+	loops := 0
+	for _, err := range errs {
+		if errors.As(err, &loop) {
+			loops++
+		}
+	}
+	// a/1/root, b/up and c/one/root
+	require.Equal(3, loops)
+}
+
+func TestWalkParallelFollowSymlinks(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir := t.TempDir()
+	makeLinkTree(require, dir)
+
+	expected := make([]string, 0)
+	expectedErrs := 0
+	w := NewWalker(LocalFS, dir, &WalkOptions{Sort: true, FollowSymlinks: true, OnError: func(path string, err error) error {
+		expectedErrs++
+		return nil
+	}})
+	for w.Next() {
+		expected = append(expected, w.Entry().Path)
+	}
+
+	paths := make([]string, 0)
+	errs := 0
+	err := WalkParallel(context.Background(), LocalFS, dir, &ParallelWalkOptions{WalkOptions{Sort: true, FollowSymlinks: true, OnError: func(path string, err error) error {
+		errs++
+		return nil
+	}}, 4}, func(entry WalkEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	})
+	require.Nil(err)
+	require.Equal(expected, paths)
+	require.Equal(expectedErrs, errs)
+	require.Equal(2, errs)
+}
+
+func TestListFollowSymlinks(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir := t.TempDir()
+	makeLinkTree(require, dir)
+
+	files, err := ListFiles(LocalFS, dir, []string{"*.txt"})
+	require.Nil(err)
+	require.Equal(3, len(files))
+	files, err = ListFiles(LocalFS, dir, []string{"*.txt"}, WithFollowSymlinks())
+	require.Nil(err)
+	require.Equal(4, len(files))
+	require.Contains(files, filepath.Join(dir, "c", "one", "y.txt"))
+
+	dirs, err := ListDirs(LocalFS, dir, []string{"c/*"})
+	require.Nil(err)
+	require.Empty(dirs)
+	dirs, err = ListDirs(LocalFS, dir, []string{"c/*"}, WithFollowSymlinks())
+	require.Nil(err)
+	require.Equal([]string{filepath.Join(dir, "c", "one")}, dirs)
+
+	// Bad links are left out
+	dirs, err = ListDirs(LocalFS, dir, []string{"**"}, WithFollowSymlinks())
+	require.Nil(err)
+	require.Equal(6, len(dirs))
+	require.NotContains(dirs, filepath.Join(dir, "b", "up"))
+}
@@ -0,0 +1,290 @@
+package easyfiles
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// FileSystemFactory creates the filesystem for the authority (host:port)
+// of URLs with a registered scheme. It is called at most once per
+// authority unless it fails.
+type FileSystemFactory func(authority string) (FileSystemInterface, error)
+
+// AuthorityNormalizer rewrites the authority of a URL into the one form
+// used for every authority that refers to the same filesystem, such as
+// by adding a default port
+type AuthorityNormalizer func(authority string) string
+
+// UnknownSchemeError is returned for URLs whose scheme hasn't been
+// registered
+type UnknownSchemeError struct {
+	Scheme string
+}
+
+func (e *UnknownSchemeError) Error() string {
+	return fmt.Sprintf("Unknown URL scheme: %v", e.Scheme)
+}
+
+type urlFileSystem struct {
+	mutex sync.Mutex
+	fs    FileSystemInterface
+}
+
+var (
+	schemeMutex     sync.Mutex
+	schemeFactories = make(map[string]FileSystemFactory)
+	normalizers     = make(map[string]AuthorityNormalizer)
+	urlFileSystems  = make(map[string]*urlFileSystem)
+
+	schemeRegex = regexp.MustCompile(`^([a-zA-Z][a-zA-Z0-9+.-]*)://`)
+)
+
+func init() {
+	RegisterScheme("file", func(authority string) (FileSystemInterface, error) {
+		if authority != "" && authority != "localhost" {
+			return nil, fmt.Errorf("file URLs can't refer to host %v", authority)
+		}
+		return LocalFS, nil
+	})
+	// Every authority is a separate MemFS: mem://a/x and mem://b/x are
+	// different files
+	RegisterScheme("mem", func(authority string) (FileSystemInterface, error) {
+		return NewMemFS(), nil
+	})
+}
+
+// RegisterScheme makes URLs with scheme resolve to filesystems created by
+// factory. Backends register themselves when their package is imported,
+// the way easyhdfs registers hdfs. It panics if scheme is already
+// registered.
+func RegisterScheme(scheme string, factory FileSystemFactory) {
+	scheme = strings.ToLower(scheme)
+	schemeMutex.Lock()
+	defer schemeMutex.Unlock()
+	if _, ok := schemeFactories[scheme]; ok {
+		panic(fmt.Sprintf("URL scheme %v registered twice", scheme))
+	}
+	schemeFactories[scheme] = factory
+}
+
+// unregisterScheme forgets scheme and the filesystems created for it, so
+// that tests can register their schemes afresh
+func unregisterScheme(scheme string) {
+	scheme = strings.ToLower(scheme)
+	schemeMutex.Lock()
+	defer schemeMutex.Unlock()
+	delete(schemeFactories, scheme)
+	delete(normalizers, scheme)
+	for key := range urlFileSystems {
+		if strings.HasPrefix(key, scheme+"://") {
+			delete(urlFileSystems, key)
+		}
+	}
+}
+
+// SetAuthorityNormalizer has the authorities of URLs with scheme
+// normalized before they are looked up, so that URLs whose authorities
+// normalize to the same string share a filesystem. The factory is passed
+// the normalized authority.
+func SetAuthorityNormalizer(scheme string, normalize AuthorityNormalizer) {
+	schemeMutex.Lock()
+	defer schemeMutex.Unlock()
+	normalizers[strings.ToLower(scheme)] = normalize
+}
+
+// DefaultPort returns an AuthorityNormalizer that lowercases host names
+// and adds port to authorities without one
+func DefaultPort(port string) AuthorityNormalizer {
+	return func(authority string) string {
+		host, p, err := net.SplitHostPort(authority)
+		if err != nil {
+			// No port; IPv6 addresses may still be bracketed
+			host, p = strings.TrimSuffix(strings.TrimPrefix(authority, "["), "]"), port
+		}
+		return net.JoinHostPort(strings.ToLower(host), p)
+	}
+}
+
+// Schemes returns the registered URL schemes, sorted
+func Schemes() []string {
+	schemeMutex.Lock()
+	defer schemeMutex.Unlock()
+	schemes := make([]string, 0, len(schemeFactories))
+	for scheme := range schemeFactories {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+	return schemes
+}
+
+// SplitURL splits rawurl into its scheme, authority and path. Anything
+// without a scheme:// prefix is a local path, and has an empty scheme.
+// The path is taken as it is, without percent-decoding, so that glob
+// patterns containing ? and # survive.
+func SplitURL(rawurl string) (scheme, authority, path string) {
+	m := schemeRegex.FindStringSubmatch(rawurl)
+	if m == nil {
+		return "", "", rawurl
+	}
+	rest := rawurl[len(m[0]):]
+	idx := strings.Index(rest, "/")
+	if idx < 0 {
+		return strings.ToLower(m[1]), rest, "/"
+	}
+	return strings.ToLower(m[1]), rest[:idx], rest[idx:]
+}
+
+// urlPrefix returns what is put before paths to turn them back into URLs
+func urlPrefix(scheme, authority string) string {
+	if scheme == "" {
+		return ""
+	}
+	return scheme + "://" + authority
+}
+
+// ResolveURL returns the filesystem rawurl refers to, creating it if this
+// is the first URL with its scheme and authority, along with the path
+// within it. Local paths resolve to LocalFS.
+func ResolveURL(rawurl string) (FileSystemInterface, string, error) {
+	scheme, authority, path := SplitURL(rawurl)
+	if scheme == "" {
+		return LocalFS, path, nil
+	}
+
+	schemeMutex.Lock()
+	factory, ok := schemeFactories[scheme]
+	if !ok {
+		schemeMutex.Unlock()
+		return nil, "", &UnknownSchemeError{scheme}
+	}
+	if normalize, ok := normalizers[scheme]; ok {
+		authority = normalize(authority)
+	}
+	key := urlPrefix(scheme, authority)
+	u, ok := urlFileSystems[key]
+	if !ok {
+		u = &urlFileSystem{}
+		urlFileSystems[key] = u
+	}
+	schemeMutex.Unlock()
+
+	// Creating a filesystem may be slow, so only hold up URLs for the
+	// same one
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	if u.fs == nil {
+		fs, err := factory(authority)
+		if err != nil {
+			return nil, "", err
+		}
+		u.fs = fs
+	}
+	return u.fs, path, nil
+}
+
+// OpenURL is Open for the file rawurl refers to
+func OpenURL(rawurl string, mode int, gz FileType) (*File, error) {
+	fs, path, err := ResolveURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return fs.Open(path, mode, gz)
+}
+
+// OpenFileURL is OpenFile for the file rawurl refers to
+func OpenFileURL(rawurl string, opts ...OpenOption) (*File, error) {
+	fs, path, err := ResolveURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return OpenFile(fs, path, opts...)
+}
+
+// StatURL is Stat for the file rawurl refers to. Unlike some
+// filesystems' Stat, it always returns an error for missing files.
+func StatURL(rawurl string) (os.FileInfo, error) {
+	fs, path, err := ResolveURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return statPath(fs, path)
+}
+
+// ExistsURL is Exists for the file rawurl refers to
+func ExistsURL(rawurl string) (bool, error) {
+	fs, path, err := ResolveURL(rawurl)
+	if err != nil {
+		return false, err
+	}
+	return fs.Exists(path)
+}
+
+// ReadFileURL is ReadFile for the file rawurl refers to
+func ReadFileURL(rawurl string) ([]byte, error) {
+	fs, path, err := ResolveURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadFile(path)
+}
+
+// WriteFileURL is WriteFile for the file rawurl refers to
+func WriteFileURL(rawurl string, b []byte, perm os.FileMode) error {
+	fs, path, err := ResolveURL(rawurl)
+	if err != nil {
+		return err
+	}
+	return fs.WriteFile(path, b, perm)
+}
+
+// RemoveURL is Remove for the file rawurl refers to
+func RemoveURL(rawurl string) error {
+	fs, path, err := ResolveURL(rawurl)
+	if err != nil {
+		return err
+	}
+	return fs.Remove(path)
+}
+
+// MakedirsURL is Makedirs for the directory rawurl refers to
+func MakedirsURL(rawurl string) error {
+	fs, path, err := ResolveURL(rawurl)
+	if err != nil {
+		return err
+	}
+	return fs.Makedirs(path)
+}
+
+// ReadDirURL is ReadDir for the directory rawurl refers to
+func ReadDirURL(rawurl string) ([]os.FileInfo, error) {
+	fs, path, err := ResolveURL(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	return fs.ReadDir(path)
+}
+
+// GlobURL is Glob for a pattern given as a URL. Matches are returned as
+// URLs with the same scheme and authority, or as local paths for a local
+// pattern.
+func GlobURL(pattern string) ([]string, error) {
+	fs, path, err := ResolveURL(pattern)
+	if err != nil {
+		return nil, err
+	}
+	matches, err := fs.Glob(path)
+	if err != nil {
+		return nil, err
+	}
+	scheme, authority, _ := SplitURL(pattern)
+	prefix := urlPrefix(scheme, authority)
+	for idx := range matches {
+		matches[idx] = prefix + matches[idx]
+	}
+	return matches, nil
+}
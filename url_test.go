@@ -0,0 +1,157 @@
+package easyfiles
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitURL(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	tests := []struct {
+		url                     string
+		scheme, authority, path string
+	}{
+		{"hdfs://nn:8020/data/x.gz", "hdfs", "nn:8020", "/data/x.gz"},
+		{"HDFS://nn/data/*.gz", "hdfs", "nn", "/data/*.gz"},
+		{"file:///tmp/x", "file", "", "/tmp/x"},
+		{"mem://fixtures", "mem", "fixtures", "/"},
+		{"mem://a/x?.txt#1", "mem", "a", "/x?.txt#1"},
+		{"/local/x.gz", "", "", "/local/x.gz"},
+		{"relative/x", "", "", "relative/x"},
+		{"c:/x", "", "", "c:/x"},
+	}
+	for _, test := range tests {
+		scheme, authority, path := SplitURL(test.url)
+		require.Equal(test.scheme, scheme, test.url)
+		require.Equal(test.authority, authority, test.url)
+		require.Equal(test.path, path, test.url)
+	}
+}
+
+func TestURLs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	require.Subset(Schemes(), []string{"file", "mem"})
+
+	// Filesystems are cached per authority
+	require.Nil(MakedirsURL("mem://test-urls/data"))
+	require.Nil(WriteFileURL("mem://test-urls/data/x.txt", []byte("x"), 0664))
+	b, err := ReadFileURL("mem://test-urls/data/x.txt")
+	require.Nil(err)
+	require.Equal("x", string(b))
+	exists, err := ExistsURL("mem://test-urls-other/data/x.txt")
+	require.Nil(err)
+	require.False(exists)
+
+	matches, err := GlobURL("mem://test-urls/**/*.txt")
+	require.Nil(err)
+	require.Equal([]string{"mem://test-urls/data/x.txt"}, matches)
+	infos, err := ReadDirURL("mem://test-urls/data")
+	require.Nil(err)
+	require.Equal(1, len(infos))
+
+	f, err := OpenFileURL("mem://test-urls/data/y.txt", WithWrite(), WithCreate())
+	require.Nil(err)
+	f.Close()
+	info, err := StatURL("mem://test-urls/data/y.txt")
+	require.Nil(err)
+	require.Equal("y.txt", info.Name())
+	require.Nil(RemoveURL("mem://test-urls/data/y.txt"))
+	_, err = StatURL("mem://test-urls/data/y.txt")
+	require.True(os.IsNotExist(err))
+
+	// Local paths and file URLs are the local filesystem
+	dir := t.TempDir()
+	p := filepath.Join(dir, "local.txt")
+	require.Nil(WriteFileURL(p, []byte("local"), 0664))
+	f, err = OpenURL("file://"+p, os.O_RDONLY, GZ_FALSE)
+	require.Nil(err)
+	f.Close()
+	matches, err = GlobURL("file://" + dir + "/*.txt")
+	require.Nil(err)
+	require.Equal([]string{"file://" + p}, matches)
+	matches, err = GlobURL(dir + "/*.txt")
+	require.Nil(err)
+	require.Equal([]string{p}, matches)
+	_, err = StatURL("file://elsewhere/x")
+	require.NotNil(err)
+
+	_, err = StatURL("nope://x/y")
+	unknown := &UnknownSchemeError{}
+	require.True(errors.As(err, &unknown))
+	require.Equal("nope", unknown.Scheme)
+}
+
+func TestRegisterScheme(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mutex := sync.Mutex{}
+	created := make([]string, 0)
+	fail := true
+	t.Cleanup(func() { unregisterScheme("test-register") })
+	RegisterScheme("test-register", func(authority string) (FileSystemInterface, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if fail {
+			return nil, errors.New("unavailable")
+		}
+		created = append(created, authority)
+		return NewMemFS(), nil
+	})
+	require.Panics(func() {
+		RegisterScheme("TEST-register", nil)
+	})
+
+	// Failures aren't cached
+	_, err := StatURL("test-register://a/")
+	require.NotNil(err)
+	fail = false
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(authority string) {
+			defer wg.Done()
+			_, err := StatURL("test-register://" + authority + "/")
+			require.Nil(err)
+		}([]string{"a", "b"}[i%2])
+	}
+	wg.Wait()
+	require.ElementsMatch([]string{"a", "b"}, created)
+}
+
+func TestAuthorityNormalizer(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	normalize := DefaultPort("8020")
+	require.Equal("nn:8020", normalize("nn"))
+	require.Equal("nn:8020", normalize("NN:8020"))
+	require.Equal("nn:9000", normalize("nn:9000"))
+	require.Equal("[::1]:8020", normalize("[::1]"))
+
+	// Authorities that normalize the same share a filesystem
+	created := make([]string, 0)
+	t.Cleanup(func() { unregisterScheme("test-normalize") })
+	RegisterScheme("test-normalize", func(authority string) (FileSystemInterface, error) {
+		created = append(created, authority)
+		return NewMemFS(), nil
+	})
+	SetAuthorityNormalizer("test-normalize", normalize)
+	require.Nil(WriteFileURL("test-normalize://nn/x", []byte("x"), 0664))
+	b, err := ReadFileURL("test-normalize://NN:8020/x")
+	require.Nil(err)
+	require.Equal("x", string(b))
+	_, err = StatURL("test-normalize://nn:9000/x")
+	require.True(os.IsNotExist(err))
+	require.Equal([]string{"nn:8020", "nn:9000"}, created)
+}
@@ -0,0 +1,56 @@
+package easyfiles
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// statPath is fs.Stat, except that a missing file is always reported as
+// an error rather than a nil FileInfo
+func statPath(fs FileSystemInterface, name string) (os.FileInfo, error) {
+	info, err := fs.Stat(name)
+	if err == nil && info == nil {
+		err = &os.PathError{Op: "stat", Path: name, Err: os.ErrNotExist}
+	}
+	return info, err
+}
+
+// Walk is filepath.Walk for any FileSystemInterface. It calls fn for root
+// and everything below it, visiting the entries of each directory in
+// lexical order so that every filesystem is walked the same way.
+// Returning filepath.SkipDir from fn for a directory skips its contents.
+func Walk(fs FileSystemInterface, root string, fn filepath.WalkFunc) error {
+	info, err := statPath(fs, root)
+	if err != nil {
+		err = fn(root, nil, err)
+	} else {
+		err = walk(fs, root, info, fn)
+	}
+	if err == filepath.SkipDir {
+		return nil
+	}
+	return err
+}
+
+func walk(fs FileSystemInterface, path string, info os.FileInfo, fn filepath.WalkFunc) error {
+	if !info.IsDir() {
+		return fn(path, info, nil)
+	}
+
+	infos, err := fs.ReadDir(path)
+	err1 := fn(path, info, err)
+	// If ReadDir failed, fn has already been told about it
+	if err != nil || err1 != nil {
+		return err1
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+	for _, child := range infos {
+		err := walk(fs, filepath.Join(path, child.Name()), child, fn)
+		if err != nil && (!child.IsDir() || err != filepath.SkipDir) {
+			return err
+		}
+	}
+	return nil
+}
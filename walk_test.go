@@ -0,0 +1,130 @@
+package easyfiles
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+var walkTree = []string{"b/x.txt", "a/2/z.gz", "a/1/y.txt", "a.txt", "c/"}
+
+func makeWalkTree(require *require.Assertions, fs FileSystemInterface, root string) {
+	for _, name := range walkTree {
+		p := filepath.Join(root, name)
+		if strings.HasSuffix(name, "/") {
+			require.Nil(fs.Makedirs(p))
+			continue
+		}
+		require.Nil(fs.Makedirs(filepath.Dir(p)))
+		require.Nil(fs.WriteFile(p, []byte(name), 0664))
+	}
+}
+
+func TestWalk(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	walked := func(fs FileSystemInterface, root string, skip string) []string {
+		paths := make([]string, 0)
+		err := Walk(fs, root, func(p string, info os.FileInfo, err error) error {
+			require.Nil(err)
+			rel, _ := filepath.Rel(root, p)
+			if info.IsDir() {
+				rel += "/"
+			}
+			paths = append(paths, rel)
+			if rel == skip {
+				return filepath.SkipDir
+			}
+			return nil
+		})
+		require.Nil(err)
+		return paths
+	}
+
+	dir := t.TempDir()
+	makeWalkTree(require, LocalFS, dir)
+	mem := NewMemFS()
+	makeWalkTree(require, mem, "/root")
+
+	expected := []string{"./", "a/", "a/1/", "a/1/y.txt", "a/2/", "a/2/z.gz", "a.txt", "b/", "b/x.txt", "c/"}
+	require.Equal(expected, walked(LocalFS, dir, ""))
+	require.Equal(expected, walked(mem, "/root", ""))
+
+	expected = []string{"./", "a/", "a.txt", "b/", "b/x.txt", "c/"}
+	require.Equal(expected, walked(LocalFS, dir, "a/"))
+	require.Equal(expected, walked(mem, "/root", "a/"))
+
+	// A missing root is reported to fn
+	var reported error
+	err := Walk(mem, "/missing", func(p string, info os.FileInfo, err error) error {
+		reported = err
+		return err
+	})
+	require.True(os.IsNotExist(err))
+	require.True(os.IsNotExist(reported))
+}
+
+func TestListFilesListDirsFS(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir := t.TempDir()
+	makeWalkTree(require, LocalFS, dir)
+	mem := NewMemFS()
+	makeWalkTree(require, mem, "/root")
+
+	rel := func(root string, paths []string) []string {
+		ret := make([]string, len(paths))
+		for idx, p := range paths {
+			ret[idx], _ = filepath.Rel(root, p)
+		}
+		return ret
+	}
+
+	for _, fs := range []struct {
+		fs   FileSystemInterface
+		root string
+	}{{LocalFS, dir}, {mem, "/root"}} {
+		files, err := ListFiles(fs.fs, fs.root, []string{"*.txt"})
+		require.Nil(err)
+		require.Equal([]string{"a.txt", "a/1/y.txt", "b/x.txt"}, rel(fs.root, files))
+
+		dirs, err := ListDirs(fs.fs, fs.root, []string{"*/"})
+		require.Nil(err)
+		require.Equal([]string{"a", "b", "c"}, rel(fs.root, dirs))
+		dirs, err = ListDirs(fs.fs, fs.root, []string{"**", "a/*"})
+		require.Nil(err)
+		require.Equal([]string{"a", "a/1", "a/2", "b", "c"}, rel(fs.root, dirs))
+
+		_, err = ListFiles(fs.fs, filepath.Join(fs.root, "missing"), []string{"*"})
+		require.NotNil(err)
+	}
+}
+
+func TestListFilesErrors(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mem := NewMemFS()
+	makeWalkTree(require, mem, "/root")
+	fs := &failingReadDirFS{mem, "/root/a"}
+
+	// Unlistable directories fail the listing unless handled
+	_, err := ListFiles(fs, "/root", []string{"*.txt"})
+	require.True(os.IsPermission(err))
+	_, err = ListDirs(fs, "/root", []string{"**"})
+	require.True(os.IsPermission(err))
+
+	errs := make([]string, 0)
+	files, err := ListFiles(fs, "/root", []string{"*.txt"}, WithOnError(func(path string, err error) error {
+		errs = append(errs, path)
+		return nil
+	}))
+	require.Nil(err)
+	require.Equal([]string{"/root/a.txt", "/root/b/x.txt"}, files)
+	require.Equal([]string{"/root/a"}, errs)
+}
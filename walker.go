@@ -0,0 +1,276 @@
+package easyfiles
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const (
+	// Number of entries read at a time from filesystems that can list
+	// directories in batches
+	WALK_BATCH_SIZE = 1024
+)
+
+// WalkEntry is a single file or directory found by a Walker
+type WalkEntry struct {
+	Path string
+	Info os.FileInfo
+	// Depth is 0 for the root, 1 for its entries and so on
+	Depth int
+}
+
+// WalkErrorHandler decides what happens when a path can't be walked.
+// Returning nil skips the path and carries on; returning an error stops
+// the walk, and Walker.Err reports that error. To collect errors, append
+// them somewhere and return nil.
+type WalkErrorHandler func(path string, err error) error
+
+// WalkOptions controls a Walker
+type WalkOptions struct {
+	// MaxDepth stops the walk from descending below this depth (see
+	// DepthLimit). The root is at depth 0, so a MaxDepth of 0 yields only
+	// the root. If nil, everything is walked.
+	MaxDepth *int
+	// Sort visits the entries of each directory in lexical order. This
+	// needs each directory to be listed in full before it is walked.
+	// Without it, entries come in whatever order the filesystem lists
+	// them, and filesystems that can list directories in batches never
+	// hold more than a batch of a directory in memory.
+	Sort bool
+	// OnError is called for every path that can't be walked. If nil, the
+	// walk stops at the first error.
+	OnError WalkErrorHandler
+	// FollowSymlinks descends into symbolic links to directories. Links
+	// pointing back at a directory the walk is inside of, compared by
+	// device and inode, are reported to OnError as a *SymlinkLoopError
+	// and links to missing targets as a *DanglingLinkError. Both are
+	// still yielded, but not descended into. A directory linked from more
+	// than one place is walked once for every link.
+	FollowSymlinks bool
+	// ReportLinkTargets yields followed links with the FileInfo of their
+	// target rather than of the link itself
+	ReportLinkTargets bool
+}
+
+// DepthLimit returns a MaxDepth of depth
+func DepthLimit(depth int) *int {
+	return &depth
+}
+
+// belowMaxDepth reports whether an entry at depth may be descended into
+func (o *WalkOptions) belowMaxDepth(depth int) bool {
+	return o.MaxDepth == nil || depth < *o.MaxDepth
+}
+
+// dirBatcher lists a directory a batch at a time, like *os.File
+type dirBatcher interface {
+	Readdir(n int) ([]os.FileInfo, error)
+	Close() error
+}
+
+// dirOpener is implemented by filesystems that can list directories in
+// batches
+type dirOpener interface {
+	openDir(name string) (dirBatcher, error)
+}
+
+type walkDir struct {
+	path      string
+	depth     int
+	infos     []os.FileInfo
+	batch     dirBatcher
+	ancestors *walkAncestor
+}
+
+func (d *walkDir) next() (os.FileInfo, error) {
+	if len(d.infos) == 0 && d.batch != nil {
+		infos, err := d.batch.Readdir(WALK_BATCH_SIZE)
+		if len(infos) == 0 {
+			if err == nil {
+				err = io.EOF
+			}
+			return nil, err
+		}
+		d.infos = infos
+	}
+	if len(d.infos) == 0 {
+		return nil, io.EOF
+	}
+	info := d.infos[0]
+	d.infos = d.infos[1:]
+	return info, nil
+}
+
+func (d *walkDir) close() {
+	if d.batch != nil {
+		d.batch.Close()
+	}
+}
+
+// Walker walks a directory tree lazily, one entry at a time, in the
+// manner of bufio.Scanner:
+//
+//	w := NewWalker(fs, root, nil)
+//	defer w.Close()
+//	for w.Next() {
+//		entry := w.Entry()
+//		..
+//	}
+//	if err := w.Err(); err != nil {
+//		..
+//	}
+//
+// Directories are yielded before their contents.
+type Walker struct {
+	fs   FileSystemInterface
+	root string
+	opts WalkOptions
+
+	stack   []*walkDir
+	entry   WalkEntry
+	pending *WalkEntry
+	// pendingInfo is the FileInfo of the directory pending points to,
+	// which differs from pending.Info for followed links
+	pendingInfo os.FileInfo
+	started     bool
+	done        bool
+	err         error
+}
+
+// NewWalker returns a Walker for root. opts may be nil.
+func NewWalker(fs FileSystemInterface, root string, opts *WalkOptions) *Walker {
+	w := &Walker{fs: fs, root: root}
+	if opts != nil {
+		w.opts = *opts
+	}
+	return w
+}
+
+// Entry returns the entry found by the last call to Next
+func (w *Walker) Entry() WalkEntry {
+	return w.entry
+}
+
+// Err returns the error that stopped the walk, if any
+func (w *Walker) Err() error {
+	return w.err
+}
+
+// SkipDir stops the Walker from descending into the current entry
+func (w *Walker) SkipDir() {
+	w.pending = nil
+}
+
+// Close releases any directories the Walker still has open. It is only
+// needed if the walk is abandoned before Next returns false.
+func (w *Walker) Close() error {
+	for _, d := range w.stack {
+		d.close()
+	}
+	w.stack = nil
+	w.done = true
+	return nil
+}
+
+// handle reports err for path and returns whether the walk should go on
+func (w *Walker) handle(path string, err error) bool {
+	if w.opts.OnError != nil {
+		err = w.opts.OnError(path, err)
+	}
+	if err != nil {
+		w.err = err
+		w.Close()
+		return false
+	}
+	return true
+}
+
+// found makes entry the current one. dirInfo is what entry resolves to,
+// or nil if it mustn't be descended into.
+func (w *Walker) found(entry WalkEntry, dirInfo os.FileInfo) {
+	w.entry = entry
+	w.pending = nil
+	if dirInfo != nil && dirInfo.IsDir() && w.opts.belowMaxDepth(entry.Depth) {
+		w.pending = &entry
+		w.pendingInfo = dirInfo
+	}
+}
+
+func (w *Walker) open(entry *WalkEntry, info os.FileInfo) error {
+	d := &walkDir{path: entry.Path, depth: entry.Depth}
+	d.ancestors = &walkAncestor{info: info}
+	if len(w.stack) > 0 {
+		d.ancestors.parent = w.stack[len(w.stack)-1].ancestors
+	}
+	if opener, ok := w.fs.(dirOpener); ok && !w.opts.Sort {
+		batch, err := opener.openDir(entry.Path)
+		if err != nil {
+			return err
+		}
+		d.batch = batch
+	} else {
+		infos, err := w.fs.ReadDir(entry.Path)
+		if err != nil {
+			return err
+		}
+		if w.opts.Sort {
+			sort.Slice(infos, func(i, j int) bool { return infos[i].Name() < infos[j].Name() })
+		}
+		d.infos = infos
+	}
+	w.stack = append(w.stack, d)
+	return nil
+}
+
+// Next advances to the next entry. It returns false once the walk is
+// over or has been stopped by an error.
+func (w *Walker) Next() bool {
+	if w.done {
+		return false
+	}
+	if !w.started {
+		w.started = true
+		info, err := statPath(w.fs, w.root)
+		if err != nil {
+			w.handle(w.root, err)
+			w.done = true
+			return false
+		}
+		w.found(WalkEntry{w.root, info, 0}, info)
+		return true
+	}
+
+	if w.pending != nil {
+		pending := w.pending
+		w.pending = nil
+		if err := w.open(pending, w.pendingInfo); err != nil && !w.handle(pending.Path, err) {
+			return false
+		}
+	}
+	for len(w.stack) > 0 {
+		top := w.stack[len(w.stack)-1]
+		info, err := top.next()
+		if err != nil {
+			top.close()
+			w.stack = w.stack[:len(w.stack)-1]
+			if err != io.EOF && !w.handle(top.path, err) {
+				return false
+			}
+			continue
+		}
+		entry := WalkEntry{filepath.Join(top.path, info.Name()), info, top.depth + 1}
+		dirInfo := info
+		if w.opts.FollowSymlinks && isSymlink(info) {
+			entry, dirInfo, err = followLink(w.fs, entry, &w.opts, top.ancestors)
+			if err != nil && !w.handle(entry.Path, err) {
+				return false
+			}
+		}
+		w.found(entry, dirInfo)
+		return true
+	}
+	w.done = true
+	return false
+}
@@ -0,0 +1,134 @@
+package easyfiles
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// failingReadDirFS fails to list one directory
+type failingReadDirFS struct {
+	*MemFS
+	bad string
+}
+
+func (f *failingReadDirFS) ReadDir(dirname string) ([]os.FileInfo, error) {
+	if dirname == f.bad {
+		return nil, &os.PathError{Op: "open", Path: dirname, Err: os.ErrPermission}
+	}
+	return f.MemFS.ReadDir(dirname)
+}
+
+func walkPaths(w *Walker, root string) []string {
+	paths := make([]string, 0)
+	for w.Next() {
+		rel, _ := filepath.Rel(root, w.Entry().Path)
+		paths = append(paths, fmt.Sprintf("%v:%d", rel, w.Entry().Depth))
+	}
+	return paths
+}
+
+func TestWalker(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mem := NewMemFS()
+	makeWalkTree(require, mem, "/root")
+
+	w := NewWalker(mem, "/root", &WalkOptions{Sort: true})
+	require.Equal([]string{".:0", "a:1", "a/1:2", "a/1/y.txt:3", "a/2:2", "a/2/z.gz:3", "a.txt:1", "b:1", "b/x.txt:2", "c:1"}, walkPaths(w, "/root"))
+	require.Nil(w.Err())
+	require.False(w.Next())
+
+	w = NewWalker(mem, "/root", &WalkOptions{Sort: true, MaxDepth: DepthLimit(1)})
+	require.Equal([]string{".:0", "a:1", "a.txt:1", "b:1", "c:1"}, walkPaths(w, "/root"))
+	w = NewWalker(mem, "/root", &WalkOptions{Sort: true, MaxDepth: DepthLimit(0)})
+	require.Equal([]string{".:0"}, walkPaths(w, "/root"))
+
+	// Skip everything below a
+	w = NewWalker(mem, "/root", &WalkOptions{Sort: true})
+	paths := make([]string, 0)
+	for w.Next() {
+		paths = append(paths, w.Entry().Path)
+		if w.Entry().Path == "/root/a" {
+			w.SkipDir()
+		}
+	}
+	require.Equal([]string{"/root", "/root/a", "/root/a.txt", "/root/b", "/root/b/x.txt", "/root/c"}, paths)
+
+	// Missing roots are errors
+	w = NewWalker(mem, "/missing", nil)
+	require.False(w.Next())
+	require.True(os.IsNotExist(w.Err()))
+}
+
+func TestWalkerErrors(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	mem := NewMemFS()
+	makeWalkTree(require, mem, "/root")
+	fs := &failingReadDirFS{mem, "/root/a/1"}
+
+	// By default the walk stops at the first error
+	w := NewWalker(fs, "/root", &WalkOptions{Sort: true})
+	require.Equal([]string{".:0", "a:1", "a/1:2"}, walkPaths(w, "/root"))
+	require.True(os.IsPermission(w.Err()))
+
+	// Collect errors and carry on
+	errs := make([]error, 0)
+	w = NewWalker(fs, "/root", &WalkOptions{Sort: true, OnError: func(path string, err error) error {
+		errs = append(errs, err)
+		return nil
+	}})
+	// Everything but a/1/y.txt
+	require.Equal(9, len(walkPaths(w, "/root")))
+	require.Nil(w.Err())
+	require.Equal(1, len(errs))
+
+	// Abort with an error of the handler's choosing
+	stop := errors.New("stop")
+	w = NewWalker(fs, "/root", &WalkOptions{OnError: func(path string, err error) error {
+		return stop
+	}})
+	walkPaths(w, "/root")
+	require.Equal(stop, w.Err())
+}
+
+func TestWalkerBatches(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	dir := t.TempDir()
+	n := WALK_BATCH_SIZE + 10
+	for idx := 0; idx < n; idx++ {
+		require.Nil(LocalFS.WriteFile(filepath.Join(dir, fmt.Sprintf("%05d", idx)), nil, 0664))
+	}
+
+	for _, sorted := range []bool{false, true} {
+		w := NewWalker(LocalFS, dir, &WalkOptions{Sort: sorted})
+		names := make([]string, 0)
+		for w.Next() {
+			if w.Entry().Depth == 1 {
+				names = append(names, w.Entry().Info.Name())
+			}
+		}
+		require.Nil(w.Err())
+		require.Equal(n, len(names))
+		if sorted {
+			require.True(sort.StringsAreSorted(names))
+		}
+	}
+
+	// Abandoned walks can be closed
+	w := NewWalker(LocalFS, dir, nil)
+	require.True(w.Next())
+	require.True(w.Next())
+	require.Nil(w.Close())
+	require.False(w.Next())
+}